@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// commandOptions holds the global flag values commands may use
+type commandOptions struct {
+	logsDir     string
+	configFile  string
+	gcloudFile  string
+	profile     string
+	allProfiles bool
+	overrides   []string
+	itemsFile   string
+	noNameCheck bool
+}
+
+// command is a subcommand like "steamquery ratelimit show"
+type command struct {
+	usage       string
+	description string
+	run         func(opts *commandOptions, args []string) error
+}
+
+var commands = map[string]command{
+	"config": {
+		usage:       "config show|schema|validate [file]|migrate <file>",
+		description: "Show the effective config, print its JSON Schema, validate a config file or carry over the fields of an old one",
+		run:         runConfigCommand,
+	},
+	"init": {
+		usage:       "init [--force]",
+		description: "Interactively create a config file and check all credentials",
+		run:         runInitCommand,
+	},
+	"ratelimit": {
+		usage:       "ratelimit show|reset [bucket]|simulate <items>",
+		description: "Inspect, reset or simulate the Steam and csgobackpack rate limits",
+		run:         runRateLimitCommand,
+	},
+}
+
+func runCommand(opts *commandOptions, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+	return cmd.run(opts, args[1:])
+}
+
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %s\n", commands[name].usage)
+		fmt.Printf("  \t%s\n", commands[name].description)
+	}
+}
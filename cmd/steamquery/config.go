@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/devusSs/steamquery/internal/config"
+	flag "github.com/spf13/pflag"
+)
+
+const redacted = "<redacted>"
+
+func runConfigCommand(opts *commandOptions, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand, expected show, schema, validate or migrate")
+	}
+
+	switch args[0] {
+	case "show":
+		return showConfig(opts)
+	case "schema":
+		return printConfigSchema()
+	case "validate":
+		return validateConfig(opts, args[1:])
+	case "migrate":
+		return migrateConfig(opts, args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand: %s", args[0])
+	}
+}
+
+// showConfig prints the effective config with the source of each value, secrets are redacted
+func showConfig(opts *commandOptions) error {
+	sources := make(map[string]config.Source)
+	cfg, err := config.Load(
+		opts.configFile,
+		config.WithProfile(opts.profile),
+		config.WithOverrides(opts.overrides),
+		config.WithSources(sources),
+	)
+	if err != nil {
+		return err
+	}
+
+	values := cfg.Values()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tVALUE\tSOURCE")
+	for _, field := range config.Fields() {
+		value := values[field.Key]
+		if !field.Print && value != "" {
+			value = redacted
+		}
+
+		source := string(sources[field.Key])
+		if source == "" {
+			source = "unset"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", field.Key, value, source)
+	}
+
+	return w.Flush()
+}
+
+func printConfigSchema() error {
+	schema, err := config.Schema()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(schema))
+
+	return nil
+}
+
+// validateConfig checks a config file (or the -c one) and all of its profiles without running
+func validateConfig(opts *commandOptions, args []string) error {
+	path := opts.configFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	profiles, err := config.Profiles(path)
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		profiles = []string{""}
+	}
+
+	failed := 0
+	for _, profile := range profiles {
+		name := "config"
+		if profile != "" {
+			name = fmt.Sprintf("profile %s", profile)
+		}
+
+		_, err := config.Load(path, config.WithProfile(profile), config.WithOverrides(opts.overrides))
+		if err != nil {
+			fmt.Printf("%s: %s\n", name, err.Error())
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s: ok\n", name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%s is invalid", path)
+	}
+
+	return nil
+}
+
+func migrateConfig(opts *commandOptions, args []string) error {
+	fs := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	out := fs.StringP("out", "o", opts.configFile, "Path to write the migrated config file to")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("missing old config file")
+	}
+
+	if _, err := os.Stat(*out); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", *out)
+	}
+
+	cfg, unmapped, err := config.Migrate(fs.Arg(0))
+	for _, key := range unmapped {
+		fmt.Printf("Field \"%s\" does not exist in the current config and was skipped\n", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Save(*out); err != nil {
+		return err
+	}
+
+	fmt.Printf("Migrated %s to %s\n", fs.Arg(0), *out)
+
+	return nil
+}
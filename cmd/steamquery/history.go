@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/customitems"
+	"github.com/devusSs/steamquery/internal/tables"
+	"google.golang.org/api/sheets/v4"
+)
+
+var historyHeader = []interface{}{"Timestamp", "Total value", "Difference", "Item count", "Currency", "Price source"}
+
+// historyEntry is a single row of the history sheet
+type historyEntry struct {
+	time       time.Time
+	total      float64
+	difference float64
+	itemCount  int
+	currency   string
+	source     string
+}
+
+// priceSource describes where the prices of the items came from,
+// e.g. "csgobackpack (7 day median) + overrides"
+func priceSource(cfg *config.Config, items []inventoryItem, customItems []customitems.Item) string {
+	itemsFilePrices := make(map[string]bool)
+	for _, item := range customItems {
+		if item.Price != nil {
+			itemsFilePrices[item.MarketHashName] = true
+		}
+	}
+
+	var market, overridden, itemsFile bool
+	for _, item := range items {
+		switch {
+		case !item.Overridden:
+			market = true
+		case itemsFilePrices[item.MarketHashName]:
+			itemsFile = true
+		default:
+			overridden = true
+		}
+	}
+
+	var sources []string
+	if market {
+		sources = append(sources, fmt.Sprintf("csgobackpack (%d day median)", cfg.MedianPriceDays))
+	}
+	if overridden {
+		sources = append(sources, "overrides")
+	}
+	if itemsFile {
+		sources = append(sources, "items file")
+	}
+	return strings.Join(sources, " + ")
+}
+
+// historyRange returns the range of the timestamps of all previous history rows
+func historyRange(cfg *config.Config) string {
+	return tables.Range(tables.Cell(cfg.HistorySheet, "A2"), "A")
+}
+
+// buildHistory appends the entry below the previous history rows, if there would be more rows
+// than cfg.HistoryRetention (0 keeps all) the oldest ones are deleted
+func buildHistory(
+	cfg *config.Config,
+	currencySign string,
+	previous *sheets.ValueRange,
+	entry historyEntry,
+) (*sheets.ValueRange, []tables.NumberFormat, []tables.Rows) {
+	data := [][]interface{}{{
+		entry.time,
+		priceValue(cfg, currencySign, entry.total),
+		priceValue(cfg, currencySign, entry.difference),
+		entry.itemCount,
+		entry.currency,
+		entry.source,
+	}}
+
+	rows := len(previous.Values)
+
+	var deleted []tables.Rows
+	if cfg.HistoryRetention > 0 && uint(rows) >= cfg.HistoryRetention {
+		drop := rows - int(cfg.HistoryRetention) + 1
+		deleted = append(deleted, tables.Rows{Sheet: cfg.HistorySheet, Start: 2, End: int64(drop) + 1})
+		rows -= drop
+	}
+
+	// Rows are deleted before writing, so the new row goes below the remaining ones.
+	next := rows + 2
+	startRow := next
+	if rows == 0 {
+		data = append([][]interface{}{historyHeader}, data...)
+		startRow = 1
+	}
+
+	formats := []tables.NumberFormat{
+		dateTimeFormat(cfg.HistorySheet, fmt.Sprintf("A%d", next), fmt.Sprintf("A%d", next)),
+		priceFormat(cfg.HistorySheet, fmt.Sprintf("B%d", next), fmt.Sprintf("C%d", next), currencySign),
+	}
+
+	return valueRange(
+		tables.Cell(cfg.HistorySheet, fmt.Sprintf("A%d", startRow)),
+		fmt.Sprintf("F%d", next),
+		data,
+	), formats, deleted
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/tables"
+	"github.com/devusSs/steamquery/pkg/steam"
+	flag "github.com/spf13/pflag"
+)
+
+// layoutKeys are the config fields describing the spreadsheet layout
+var layoutKeys = []string{
+	"starting_row",
+	"item_column",
+	"amount_column",
+	"single_price_column",
+	"total_price_column",
+	"override_column",
+	"last_updated_cell",
+	"error_cell",
+	"total_value_cell",
+	"difference_cell",
+}
+
+func runInitCommand(opts *commandOptions, args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := fs.Bool("force", false, "Overwrite the config file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(opts.configFile); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", opts.configFile)
+	}
+
+	limiter, err := newLimiter(opts.logsDir)
+	if err != nil {
+		return err
+	}
+
+	w := &wizard{
+		in:          bufio.NewReader(os.Stdin),
+		steamClient: steam.NewClient(steam.WithLimiter(limiter.Bucket(steamBucket.Name))),
+	}
+
+	fmt.Println(appMessage)
+	fmt.Println()
+	fmt.Println("This wizard will create the config file at", opts.configFile)
+	fmt.Println("Press enter to accept the [default] value of a question.")
+	fmt.Println()
+
+	cfg, gcloudFile, err := w.run(opts)
+	if saveErr := limiter.Save(); saveErr != nil {
+		fmt.Printf("Error saving rate limit state: %s\n", saveErr.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Save(opts.configFile); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Wrote config file to", opts.configFile)
+	if gcloudFile != opts.gcloudFile {
+		fmt.Printf("Remember to run steamquery with -g %s\n", gcloudFile)
+	}
+
+	return nil
+}
+
+type wizard struct {
+	in          *bufio.Reader
+	steamClient *steam.Client
+	// failed probes, the user decides whether to write the config anyway
+	failed []string
+}
+
+// run asks all questions and returns the config and Google credentials file path
+func (w *wizard) run(opts *commandOptions) (*config.Config, string, error) {
+	cfg := config.Default()
+
+	apiKey, err := w.ask("Steam API key (https://steamcommunity.com/dev/apikey)", "")
+	if err != nil {
+		return nil, "", err
+	}
+	if err := cfg.Set("steam_api_key", apiKey); err != nil {
+		return nil, "", err
+	}
+
+	fmt.Print("Checking Steam API key... ")
+	status, err := w.steamClient.GetSteamStatus(apiKey)
+	switch {
+	case err != nil:
+		w.fail("Steam API key: %v", err)
+	case !status.IsOnline():
+		fmt.Println("ok, but Steam services are currently offline")
+	default:
+		fmt.Println("ok")
+	}
+
+	steamID, err := w.askSteamID(apiKey)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg.SteamUserID64 = steamID
+
+	fmt.Print("Checking inventory visibility... ")
+	inv, err := w.steamClient.GetInventory(steamID)
+	switch {
+	case errors.Is(err, steam.ErrInventoryPrivate):
+		w.fail("inventory: your inventory is private, please make it public in your Steam privacy settings")
+	case err != nil:
+		w.fail("inventory: %v", err)
+	default:
+		fmt.Printf("ok, %d item(s)\n", len(inv.Descriptions))
+	}
+
+	currency, err := w.ask("Currency (ISO 4217 code)", cfg.Currency)
+	if err != nil {
+		return nil, "", err
+	}
+	currency = strings.ToUpper(currency)
+	if _, err := steam.GetCurrencySignByCode(currency); err != nil {
+		return nil, "", err
+	}
+	cfg.Currency = currency
+
+	spreadsheetID, err := w.ask("Spreadsheet ID (from https://docs.google.com/spreadsheets/d/<ID>/edit)", "")
+	if err != nil {
+		return nil, "", err
+	}
+	cfg.SpreadSheetID = spreadsheetID
+
+	gcloudFile, err := w.ask("Path to Google service account credentials file", opts.gcloudFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fmt.Print("Checking spreadsheet access... ")
+	svc, err := tables.NewSpreadsheetService(gcloudFile, spreadsheetID)
+	if err == nil {
+		err = svc.Test()
+	}
+	if err != nil {
+		w.fail("spreadsheet: %v (did you share the spreadsheet with the service account email?)", err)
+	} else {
+		fmt.Println("ok")
+	}
+
+	if err := w.askLayout(cfg); err != nil {
+		return nil, "", err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if len(w.failed) > 0 {
+		fmt.Println()
+		fmt.Println("The following checks failed:")
+		for _, failed := range w.failed {
+			fmt.Printf("  - %s\n", failed)
+		}
+
+		write, err := w.ask("Write the config file anyway? (y/n)", "n")
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(strings.ToLower(write), "y") {
+			return nil, "", fmt.Errorf("aborted, config file not written")
+		}
+	}
+
+	return cfg, gcloudFile, nil
+}
+
+// askSteamID accepts a SteamID64, a vanity name or a profile url
+func (w *wizard) askSteamID(apiKey string) (uint64, error) {
+	for {
+		input, err := w.ask("SteamID64, custom profile name or profile url", "")
+		if err != nil {
+			return 0, err
+		}
+
+		name := input
+		if u, err := url.Parse(input); err == nil && u.Host != "" {
+			parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+			if len(parts) == 2 && (parts[0] == "id" || parts[0] == "profiles") {
+				name = parts[1]
+			}
+		}
+
+		if steamID, err := strconv.ParseUint(name, 10, 64); err == nil {
+			return steamID, nil
+		}
+
+		steamID, err := w.steamClient.ResolveVanityURL(apiKey, name)
+		if err != nil {
+			fmt.Printf("Could not resolve %s: %s\n", name, err.Error())
+			continue
+		}
+
+		fmt.Printf("Resolved %s to %d\n", name, steamID)
+		return steamID, nil
+	}
+}
+
+func (w *wizard) askLayout(cfg *config.Config) error {
+	custom, err := w.ask("Customize the spreadsheet layout (rows, columns and cells)? (y/n)", "n")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.ToLower(custom), "y") {
+		return nil
+	}
+
+	defaults := make(map[string]string)
+	for _, field := range config.Fields() {
+		defaults[field.Key] = field.Default
+	}
+
+	for _, key := range layoutKeys {
+		for {
+			value, err := w.ask(strings.ReplaceAll(key, "_", " "), defaults[key])
+			if err != nil {
+				return err
+			}
+			if err := cfg.Set(key, value); err != nil {
+				fmt.Printf("Invalid value: %s\n", err.Error())
+				continue
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+func (w *wizard) ask(question string, def string) (string, error) {
+	for {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", question, def)
+		} else {
+			fmt.Printf("%s: ", question)
+		}
+
+		line, err := w.in.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("reading input: %w", err)
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if answer != "" {
+			return answer, nil
+		}
+	}
+}
+
+func (w *wizard) fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Println("failed")
+	fmt.Printf("  %s\n", msg)
+	w.failed = append(w.failed, msg)
+}
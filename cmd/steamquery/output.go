@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/format"
+	"github.com/devusSs/steamquery/internal/routes"
+	"github.com/devusSs/steamquery/internal/sheetstate"
+	"github.com/devusSs/steamquery/internal/steam/filter"
+	"github.com/devusSs/steamquery/internal/tables"
+	"github.com/devusSs/steamquery/pkg/steam"
+	"google.golang.org/api/sheets/v4"
+)
+
+// output holds the items routed to a single destination
+type output struct {
+	destination routes.Destination
+	items       []inventoryItem
+}
+
+// route distributes the items to the destinations of the routes file, unrouted items go
+// to the config layout if there is no catch-all destination
+//
+// Items of the items file can only be routed by name since they have no tags.
+func (r *runner) route(cfg *config.Config, inv steam.SteamInventoryResponse, items []inventoryItem) []output {
+	descriptions := descriptionsByName(inv)
+
+	outputs := make([]output, 0, len(r.routes.Destinations)+1)
+	index := make(map[string]int, len(r.routes.Destinations)+1)
+	for _, d := range r.routes.Destinations {
+		index[d.Name] = len(outputs)
+		outputs = append(outputs, output{destination: d.WithDefaults(cfg)})
+	}
+
+	main := ""
+	if _, ok := r.routes.CatchAll(); !ok {
+		d := routes.Main(cfg)
+		main = d.Name
+		index[main] = len(outputs)
+		outputs = append(outputs, output{destination: d})
+	}
+
+	for _, item := range items {
+		name := r.routes.Route(descriptions.get(item.MarketHashName))
+		if name == "" {
+			name = main
+		}
+
+		i := index[name]
+		outputs[i].items = append(outputs[i].items, item)
+	}
+
+	for _, out := range outputs {
+		r.logger.Debug("routed %d item(s) to destination %s", len(out.items), out.destination.Name)
+	}
+
+	return outputs
+}
+
+type descriptions map[string]steam.InventoryDescription
+
+func descriptionsByName(inv steam.SteamInventoryResponse) descriptions {
+	d := make(descriptions, len(inv.Descriptions))
+	for _, desc := range inv.Descriptions {
+		d[desc.MarketHashName] = desc
+	}
+	return d
+}
+
+// get returns the description of the item, items of the items file only have a name
+func (d descriptions) get(marketHashName string) steam.InventoryDescription {
+	if desc, ok := d[marketHashName]; ok {
+		return desc
+	}
+	return steam.InventoryDescription{MarketHashName: marketHashName}
+}
+
+// totalRange returns the range of the destination total cell to read before the run,
+// empty if the destination has no difference cell
+func (o output) totalRange() string {
+	d := o.destination
+	if d.DifferenceCell == "" || d.TotalValueCell == "" {
+		return ""
+	}
+	return tables.Range(tables.Cell(d.Sheet, d.TotalValueCell), d.TotalValueCell)
+}
+
+// builtOutput holds the value ranges to write for a destination
+type builtOutput struct {
+	data  []*sheets.ValueRange
+	total float64
+	// item rows written to each of columns
+	columns []string
+	rows    sheetstate.Rows
+	// number formats of the price cells, applied in numeric mode only
+	formats []tables.NumberFormat
+}
+
+// buildOutput applies the value filters to the items of the destination and returns
+// the value ranges to write along with the total value of the destination
+func (r *runner) buildOutput(
+	cfg *config.Config,
+	currencySign string,
+	out output,
+	preTotal float64,
+) builtOutput {
+	d := out.destination
+
+	items, removed := filter.FilterByValue(r.filter, out.items, r.logger)
+
+	r.logger.Debug("applied value filters: %d item(s) kept, %d item(s) removed", len(items), len(removed))
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].MarketHashName < items[j].MarketHashName
+	})
+
+	// The other row goes last so it does not disturb the sorted items.
+	if r.filter.CollapseOther && len(removed) > 0 {
+		items = append(items, collapseOther(removed))
+	}
+
+	startRow := d.StartingRow
+	endRow := startRow + uint(len(items))
+
+	r.logger.Debug("will write to rows %d-%d", startRow, endRow)
+
+	columns := []struct {
+		column string
+		price  bool
+		value  func(item inventoryItem) interface{}
+	}{
+		{d.ItemColumn, false, func(item inventoryItem) interface{} {
+			return item.MarketHashName
+		}},
+		{d.AmountColumn, false, func(item inventoryItem) interface{} {
+			return item.Amount
+		}},
+		{d.SinglePriceColumn, true, func(item inventoryItem) interface{} {
+			if item.Other {
+				return ""
+			}
+			return priceValue(cfg, currencySign, item.Price)
+		}},
+		{d.TotalPriceColumn, true, func(item inventoryItem) interface{} {
+			return priceValue(cfg, currencySign, item.TotalValue())
+		}},
+		{d.OverrideColumn, false, func(item inventoryItem) interface{} {
+			if item.Overridden {
+				return overrideMarker
+			}
+			return ""
+		}},
+		{d.CostBasisColumn, true, func(item inventoryItem) interface{} {
+			if item.Purchase.Amount == 0 {
+				return ""
+			}
+			return priceValue(cfg, currencySign, item.Purchase.Cost)
+		}},
+		{d.ProfitColumn, true, func(item inventoryItem) interface{} {
+			if item.Purchase.Amount == 0 {
+				return ""
+			}
+			// Only the units with a purchase price count, the rest may come from the inventory.
+			return priceValue(cfg, currencySign, item.Price*float64(item.Purchase.Amount)-item.Purchase.Cost)
+		}},
+		{d.PurchaseDateColumn, false, func(item inventoryItem) interface{} {
+			return item.Purchase.FirstDate
+		}},
+		{d.NotesColumn, false, func(item inventoryItem) interface{} {
+			return strings.Join(item.Purchase.Notes, "; ")
+		}},
+	}
+
+	built := builtOutput{
+		data: make([]*sheets.ValueRange, 0, len(columns)+2),
+		rows: sheetstate.Rows{Start: startRow, End: startRow + uint(len(items)) - 1},
+	}
+	for _, c := range columns {
+		// The purchase columns are optional.
+		if c.column == "" {
+			continue
+		}
+		built.columns = append(built.columns, c.column)
+
+		values := make([][]interface{}, 0, len(items))
+		for _, item := range items {
+			values = append(values, []interface{}{c.value(item)})
+		}
+
+		built.data = append(built.data, valueRange(
+			tables.Cell(d.Sheet, fmt.Sprintf("%s%d", c.column, startRow)),
+			fmt.Sprintf("%s%d", c.column, endRow),
+			values,
+		))
+
+		if c.price && !built.rows.Empty() {
+			built.formats = append(built.formats, priceFormat(
+				d.Sheet,
+				fmt.Sprintf("%s%d", c.column, built.rows.Start),
+				fmt.Sprintf("%s%d", c.column, built.rows.End),
+				currencySign,
+			))
+		}
+	}
+
+	for _, item := range items {
+		built.total += item.TotalValue()
+	}
+
+	if d.TotalValueCell != "" {
+		built.data = append(built.data, cellValue(
+			tables.Cell(d.Sheet, d.TotalValueCell),
+			d.TotalValueCell,
+			priceValue(cfg, currencySign, built.total),
+		))
+		built.formats = append(built.formats, priceFormat(d.Sheet, d.TotalValueCell, d.TotalValueCell, currencySign))
+	}
+
+	if out.totalRange() != "" {
+		built.data = append(built.data, cellValue(
+			tables.Cell(d.Sheet, d.DifferenceCell),
+			d.DifferenceCell,
+			priceValue(cfg, currencySign, built.total-preTotal),
+		))
+		built.formats = append(built.formats, priceFormat(d.Sheet, d.DifferenceCell, d.DifferenceCell, currencySign))
+	}
+
+	return built
+}
+
+// staleRanges returns the ranges of the rows written by the previous run of the destination
+// but not by this one, and the rows of this run to record in state once they are written
+func staleRanges(
+	state *sheetstate.State,
+	cfg *config.Config,
+	d routes.Destination,
+	built builtOutput,
+) ([]string, map[string]sheetstate.Rows) {
+	var ranges []string
+	written := make(map[string]sheetstate.Rows, len(built.columns))
+	for _, column := range built.columns {
+		key := sheetstate.Key(cfg.SpreadSheetID, d.Sheet, column)
+		if previous, ok := state.Rows(key); ok {
+			for _, rows := range previous.Stale(built.rows) {
+				ranges = append(ranges, tables.Range(
+					tables.Cell(d.Sheet, fmt.Sprintf("%s%d", column, rows.Start)),
+					fmt.Sprintf("%s%d", column, rows.End),
+				))
+			}
+		}
+		written[key] = built.rows
+	}
+	return ranges, written
+}
+
+func valueRange(startCell string, endCell string, values [][]interface{}) *sheets.ValueRange {
+	return &sheets.ValueRange{
+		Range:  tables.Range(startCell, endCell),
+		Values: values,
+	}
+}
+
+// cellValue returns the value range of a single cell, cell may be prefixed by a sheet
+func cellValue(cell string, plainCell string, value interface{}) *sheets.ValueRange {
+	return valueRange(cell, plainCell, [][]interface{}{{value}})
+}
+
+// firstValue returns the first value of the range, nil if it is empty
+func firstValue(values *sheets.ValueRange) interface{} {
+	if len(values.Values) == 0 || len(values.Values[0]) == 0 {
+		return nil
+	}
+	return values.Values[0][0]
+}
+
+// priceValue returns the price as a number in numeric mode, else formatted by format.FormatPricePrintable
+func priceValue(cfg *config.Config, currencySign string, price float64) interface{} {
+	if cfg.NumericPrices {
+		return math.Round(price*100) / 100
+	}
+	return format.FormatPricePrintable(price, cfg.DecimalSeparator, currencySign)
+}
+
+// percentValue returns the percentage as a fraction in numeric mode, else formatted by format.FormatPercentPrintable
+func percentValue(cfg *config.Config, percent float64) interface{} {
+	if cfg.NumericPrices {
+		return math.Round(percent*100) / 10000
+	}
+	return format.FormatPercentPrintable(percent, cfg.DecimalSeparator)
+}
+
+// dateTimeType is the number format type of timestamps
+const dateTimeType = "DATE_TIME"
+
+// dateTimeFormat shows date and time numbers like time.DateTime
+func dateTimeFormat(sheet string, startCell string, endCell string) tables.NumberFormat {
+	return tables.NumberFormat{
+		Sheet:     sheet,
+		StartCell: startCell,
+		EndCell:   endCell,
+		Type:      dateTimeType,
+		Pattern:   "yyyy-mm-dd hh:mm:ss",
+	}
+}
+
+// priceFormat returns the currency number format for the cells, the decimal and
+// thousands separators are taken from the locale of the spreadsheet
+func priceFormat(sheet string, startCell string, endCell string, currencySign string) tables.NumberFormat {
+	return tables.NumberFormat{
+		Sheet:     sheet,
+		StartCell: startCell,
+		EndCell:   endCell,
+		Type:      "CURRENCY",
+		Pattern:   fmt.Sprintf(`#,##0.00"%s"`, currencySign),
+	}
+}
+
+// percentFormat returns the percent number format for the cells
+func percentFormat(sheet string, startCell string, endCell string) tables.NumberFormat {
+	return tables.NumberFormat{
+		Sheet:     sheet,
+		StartCell: startCell,
+		EndCell:   endCell,
+		Type:      "PERCENT",
+		Pattern:   "0.00%",
+	}
+}
+
+// parsePrice parses a number read unformatted or a price formatted by format.FormatPricePrintable,
+// an empty value is 0
+func parsePrice(value interface{}, cfg *config.Config, currencySign string) (float64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	if price, ok := value.(float64); ok {
+		return price, nil
+	}
+	priceStr := format.FormatPriceCalculatable(fmt.Sprint(value), cfg.DecimalSeparator, currencySign)
+	if priceStr == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(priceStr, 64)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/tables"
+	"google.golang.org/api/sheets/v4"
+)
+
+var priceHistoryHeader = []interface{}{"Timestamp", "Item", "Unit price", "Amount"}
+
+// priceHistoryNameHeader is the header of the item column in the wide format
+const priceHistoryNameHeader = "Item"
+
+// priceHistoryRanges returns the ranges needed to append to the price history sheet,
+// the timestamps of the previous rows for the long format, the header row and item column
+// for the wide format
+func priceHistoryRanges(cfg *config.Config) []string {
+	if cfg.PriceHistoryFormat == "wide" {
+		return []string{
+			tables.Range(tables.Cell(cfg.PriceHistorySheet, "1"), "1"),
+			tables.Range(tables.Cell(cfg.PriceHistorySheet, "A2"), "A"),
+		}
+	}
+	return []string{tables.Range(tables.Cell(cfg.PriceHistorySheet, "A2"), "A")}
+}
+
+// buildPriceHistory records the unit price of every item at t, previous are the values
+// of the ranges returned by priceHistoryRanges
+//
+// Like the history, at most cfg.HistoryRetention runs (0 keeps all) are kept,
+// the rows or columns of the oldest runs are deleted.
+func buildPriceHistory(
+	cfg *config.Config,
+	currencySign string,
+	previous []*sheets.ValueRange,
+	t time.Time,
+	items []inventoryItem,
+) tables.Update {
+	sorted := slices.Clone(items)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MarketHashName < sorted[j].MarketHashName
+	})
+
+	if cfg.PriceHistoryFormat == "wide" {
+		return buildWidePriceHistory(cfg, currencySign, previous[0], previous[1], t, sorted)
+	}
+	return buildLongPriceHistory(cfg, currencySign, previous[0], t, sorted)
+}
+
+// buildLongPriceHistory appends a row per item below the previous rows,
+// consecutive rows with the same timestamp belong to the same run
+func buildLongPriceHistory(
+	cfg *config.Config,
+	currencySign string,
+	previous *sheets.ValueRange,
+	t time.Time,
+	items []inventoryItem,
+) tables.Update {
+	var update tables.Update
+	if len(items) == 0 {
+		return update
+	}
+
+	rows := len(previous.Values)
+
+	if cfg.HistoryRetention > 0 {
+		// rows of each previous run, oldest first
+		var runs []int
+		last := ""
+		for i, values := range previous.Values {
+			timestamp := ""
+			if len(values) > 0 {
+				timestamp = fmt.Sprint(values[0])
+			}
+			if i == 0 || timestamp != last {
+				runs = append(runs, 0)
+			}
+			runs[len(runs)-1]++
+			last = timestamp
+		}
+
+		if uint(len(runs)) >= cfg.HistoryRetention {
+			drop := 0
+			for _, runRows := range runs[:len(runs)-int(cfg.HistoryRetention)+1] {
+				drop += runRows
+			}
+			update.DeleteRows = append(update.DeleteRows, tables.Rows{
+				Sheet: cfg.PriceHistorySheet,
+				Start: 2,
+				End:   int64(drop) + 1,
+			})
+			rows -= drop
+		}
+	}
+
+	var data [][]interface{}
+	// Rows are deleted before writing, so the new rows go below the remaining ones.
+	startRow := rows + 2
+	if rows == 0 {
+		data = append(data, priceHistoryHeader)
+		startRow = 1
+	}
+	for _, item := range items {
+		data = append(data, []interface{}{
+			t,
+			item.MarketHashName,
+			priceValue(cfg, currencySign, item.Price),
+			item.Amount,
+		})
+	}
+	endRow := startRow + len(data) - 1
+
+	firstItemRow := endRow - len(items) + 1
+	update.Formats = []tables.NumberFormat{
+		dateTimeFormat(cfg.PriceHistorySheet, fmt.Sprintf("A%d", firstItemRow), fmt.Sprintf("A%d", endRow)),
+		priceFormat(cfg.PriceHistorySheet, fmt.Sprintf("C%d", firstItemRow), fmt.Sprintf("C%d", endRow), currencySign),
+	}
+
+	update.Values = []*sheets.ValueRange{
+		valueRange(
+			tables.Cell(cfg.PriceHistorySheet, fmt.Sprintf("A%d", startRow)),
+			fmt.Sprintf("D%d", endRow),
+			data,
+		),
+	}
+
+	return update
+}
+
+// buildWidePriceHistory adds a column for this run right of the previous ones, items not yet
+// in the item column are appended below it, items no longer owned are left empty
+func buildWidePriceHistory(
+	cfg *config.Config,
+	currencySign string,
+	header *sheets.ValueRange,
+	names *sheets.ValueRange,
+	t time.Time,
+	items []inventoryItem,
+) tables.Update {
+	var update tables.Update
+
+	column := 1
+	if len(header.Values) > 0 && len(header.Values[0]) > 0 {
+		column = len(header.Values[0])
+	} else {
+		update.Values = append(update.Values, cellValue(
+			tables.Cell(cfg.PriceHistorySheet, "A1"),
+			"A1",
+			priceHistoryNameHeader,
+		))
+	}
+
+	// Every column right of the item column is a run.
+	if runs := column - 1; cfg.HistoryRetention > 0 && uint(runs) >= cfg.HistoryRetention {
+		drop := runs - int(cfg.HistoryRetention) + 1
+		update.DeleteColumns = append(update.DeleteColumns, tables.Columns{
+			Sheet: cfg.PriceHistorySheet,
+			Start: tables.ColumnName(1),
+			End:   tables.ColumnName(drop),
+		})
+		column -= drop
+	}
+	columnName := tables.ColumnName(column)
+
+	rows := make(map[string]int, len(names.Values))
+	for i, values := range names.Values {
+		if len(values) > 0 && values[0] != "" {
+			rows[fmt.Sprint(values[0])] = i + 2
+		}
+	}
+
+	firstNewRow := len(names.Values) + 2
+	lastRow := firstNewRow - 1
+	var newNames [][]interface{}
+	prices := make(map[int]float64, len(items))
+	for _, item := range items {
+		row, ok := rows[item.MarketHashName]
+		if !ok {
+			lastRow++
+			row = lastRow
+			rows[item.MarketHashName] = row
+			newNames = append(newNames, []interface{}{item.MarketHashName})
+		}
+		prices[row] = item.Price
+	}
+
+	update.Values = append(update.Values, cellValue(tables.Cell(cfg.PriceHistorySheet, columnName+"1"), columnName+"1", t))
+	update.Formats = append(update.Formats, dateTimeFormat(cfg.PriceHistorySheet, columnName+"1", columnName+"1"))
+
+	if len(newNames) > 0 {
+		update.Values = append(update.Values, valueRange(
+			tables.Cell(cfg.PriceHistorySheet, fmt.Sprintf("A%d", firstNewRow)),
+			fmt.Sprintf("A%d", lastRow),
+			newNames,
+		))
+	}
+
+	if lastRow < 2 {
+		return update
+	}
+
+	values := make([][]interface{}, 0, lastRow-1)
+	for row := 2; row <= lastRow; row++ {
+		price, ok := prices[row]
+		if !ok {
+			values = append(values, []interface{}{""})
+			continue
+		}
+		values = append(values, []interface{}{priceValue(cfg, currencySign, price)})
+	}
+
+	startCell := fmt.Sprintf("%s2", columnName)
+	endCell := fmt.Sprintf("%s%d", columnName, lastRow)
+	update.Values = append(update.Values, valueRange(tables.Cell(cfg.PriceHistorySheet, startCell), endCell, values))
+	update.Formats = append(update.Formats, priceFormat(cfg.PriceHistorySheet, startCell, endCell, currencySign))
+
+	return update
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/devusSs/steamquery/internal/catalogue"
+	"github.com/devusSs/steamquery/internal/customitems"
+	"github.com/devusSs/steamquery/internal/ratelimit"
+	"github.com/devusSs/steamquery/pkg/log"
+)
+
+var (
+	steamBucket    = ratelimit.Bucket{Name: "steam", Limit: 15, Window: time.Minute}
+	backpackBucket = ratelimit.Bucket{Name: "backpack", Limit: 1000, Window: time.Hour}
+)
+
+// steamRequests returns the Steam requests of a run, the status once
+// plus one inventory per user, shared by all profiles
+func steamRequests(profiles []profile) int {
+	steamIDs := make(map[uint64]bool)
+	for _, p := range profiles {
+		steamIDs[p.cfg.SteamUserID64] = true
+	}
+	return 1 + len(steamIDs)
+}
+
+// nameCheckEnabled returns true if the items file names are checked before the run
+func nameCheckEnabled(items []customitems.Item, noNameCheck bool) bool {
+	return len(items) > 0 && !noNameCheck
+}
+
+// nameCheckRequests returns the csgobackpack requests of the name check,
+// the item list is only fetched if it is not cached
+func nameCheckRequests(items []customitems.Item, logsDir string, noNameCheck bool) int {
+	if !nameCheckEnabled(items, noNameCheck) {
+		return 0
+	}
+	if catalogue.Fresh(filepath.Join(logsDir, catalogue.DefaultFileName), catalogue.DefaultMaxAge) {
+		return 0
+	}
+	return 1
+}
+
+// quotaWaiter either fails or waits (up to maxWait) when a bucket has no budget left
+type quotaWaiter struct {
+	limiter  *ratelimit.Limiter
+	wait     bool
+	maxWait  time.Duration
+	progress bool
+	logger   *log.Logger
+}
+
+func (q *quotaWaiter) waitFor(bucket ratelimit.Bucket, n int) error {
+	// Other processes may have used up budget in the meantime.
+	if err := q.limiter.Refresh(); err != nil {
+		return err
+	}
+
+	if q.limiter.Allow(bucket.Name, n) {
+		return nil
+	}
+
+	if !q.wait {
+		return fmt.Errorf("%s budget exhausted, retry later or use --wait", bucket.Name)
+	}
+
+	at, err := q.limiter.AvailableAt(bucket.Name, n)
+	if err != nil {
+		return err
+	}
+
+	d := time.Until(at).Round(time.Second)
+	if d > q.maxWait {
+		return fmt.Errorf(
+			"%s budget frees up in %s which exceeds max wait of %s",
+			bucket.Name,
+			d,
+			q.maxWait,
+		)
+	}
+
+	q.logger.Info("Waiting %s for %s rate limit budget", d, bucket.Name)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for remaining := time.Until(at); remaining > 0; remaining = time.Until(at) {
+		if q.progress {
+			fmt.Printf("\rWaiting for %s rate limit: %s remaining   ", bucket.Name, remaining.Round(time.Second))
+		}
+		<-ticker.C
+	}
+
+	if q.progress {
+		fmt.Println()
+	}
+
+	if err := q.limiter.Refresh(); err != nil {
+		return err
+	}
+
+	if !q.limiter.Allow(bucket.Name, n) {
+		return fmt.Errorf("%s budget still exhausted after waiting", bucket.Name)
+	}
+
+	q.logger.Debug("waited for %s rate limit budget", bucket.Name)
+
+	return nil
+}
+
+func newLimiter(logsDir string) (*ratelimit.Limiter, error) {
+	return ratelimit.New(
+		filepath.Join(logsDir, ratelimit.DefaultFileName),
+		steamBucket,
+		backpackBucket,
+	)
+}
+
+func runRateLimitCommand(opts *commandOptions, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand, expected show, reset or simulate")
+	}
+
+	limiter, err := newLimiter(opts.logsDir)
+	if err != nil {
+		return err
+	}
+
+	if backup := limiter.Recovered(); backup != "" {
+		fmt.Printf("Rate limit state was corrupted and has been reset, moved it to %s\n", backup)
+	}
+
+	switch args[0] {
+	case "show":
+		return showRateLimits(limiter)
+	case "reset":
+		return resetRateLimits(limiter, args[1:])
+	case "simulate":
+		return simulateRateLimits(opts, limiter, args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand: %s", args[0])
+	}
+}
+
+func showRateLimits(limiter *ratelimit.Limiter) error {
+	for _, bucket := range []ratelimit.Bucket{steamBucket, backpackBucket} {
+		remaining := limiter.Remaining(bucket.Name)
+
+		resetStr := "now"
+		if resetAt := limiter.ResetAt(bucket.Name); !resetAt.IsZero() {
+			resetStr = fmt.Sprintf(
+				"%s (in %s)",
+				resetAt.Format(time.DateTime),
+				time.Until(resetAt).Round(time.Second),
+			)
+		}
+
+		fmt.Printf("%s:\n", bucket.Name)
+		fmt.Printf("  Limit:\t\t%d per %s\n", bucket.Limit, bucket.Window)
+		fmt.Printf("  Remaining:\t%d\n", remaining)
+		fmt.Printf("  Full reset:\t%s\n", resetStr)
+	}
+
+	return nil
+}
+
+func resetRateLimits(limiter *ratelimit.Limiter, args []string) error {
+	buckets := []ratelimit.Bucket{steamBucket, backpackBucket}
+	if len(args) > 0 {
+		bucket, err := bucketByName(args[0])
+		if err != nil {
+			return err
+		}
+		buckets = []ratelimit.Bucket{bucket}
+	}
+
+	for _, bucket := range buckets {
+		limiter.Reset(bucket.Name)
+	}
+
+	if err := limiter.Save(); err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		fmt.Printf("Reset %s rate limit\n", bucket.Name)
+	}
+
+	return nil
+}
+
+// simulateRateLimits predicts a run of the selected profiles (see --profile and --all-profiles)
+// with the given amount of items to price, including the name check of the items file
+func simulateRateLimits(opts *commandOptions, limiter *ratelimit.Limiter, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing amount of items to simulate")
+	}
+
+	items, err := strconv.Atoi(args[0])
+	if err != nil || items < 0 {
+		return fmt.Errorf("invalid amount of items: %s", args[0])
+	}
+
+	profiles, err := loadProfiles(opts.configFile, opts.profile, opts.allProfiles, opts.overrides)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	customItems, err := customitems.Load(opts.itemsFile)
+	if err != nil {
+		return fmt.Errorf("loading items file: %w", err)
+	}
+
+	pass := true
+	for _, sim := range []struct {
+		bucket ratelimit.Bucket
+		needed int
+	}{
+		{steamBucket, steamRequests(profiles)},
+		{backpackBucket, items + nameCheckRequests(customItems, opts.logsDir, opts.noNameCheck)},
+	} {
+		remaining := limiter.Remaining(sim.bucket.Name)
+		fmt.Printf("%s: %d request(s) needed, %d remaining: ", sim.bucket.Name, sim.needed, remaining)
+
+		if sim.needed <= remaining {
+			fmt.Println("ok")
+			continue
+		}
+
+		pass = false
+		at, err := limiter.AvailableAt(sim.bucket.Name, sim.needed)
+		if err != nil {
+			fmt.Printf("never passes, %v\n", err)
+			continue
+		}
+		fmt.Printf(
+			"exceeded, enough budget at %s (in %s)\n",
+			at.Format(time.DateTime),
+			time.Until(at).Round(time.Second),
+		)
+	}
+
+	if pass {
+		fmt.Printf("A run with %d item(s) would pass\n", items)
+	} else {
+		fmt.Printf("A run with %d item(s) would be rate limited\n", items)
+	}
+
+	return nil
+}
+
+func bucketByName(name string) (ratelimit.Bucket, error) {
+	for _, bucket := range []ratelimit.Bucket{steamBucket, backpackBucket} {
+		if bucket.Name == name {
+			return bucket, nil
+		}
+	}
+	return ratelimit.Bucket{}, fmt.Errorf("unknown bucket: %s", name)
+}
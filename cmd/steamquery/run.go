@@ -0,0 +1,538 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/devusSs/steamquery/internal/backpack"
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/customitems"
+	"github.com/devusSs/steamquery/internal/overrides"
+	"github.com/devusSs/steamquery/internal/ratelimit"
+	"github.com/devusSs/steamquery/internal/routes"
+	"github.com/devusSs/steamquery/internal/sheetstate"
+	"github.com/devusSs/steamquery/internal/steam/filter"
+	"github.com/devusSs/steamquery/internal/tables"
+	"github.com/devusSs/steamquery/pkg/log"
+	"github.com/devusSs/steamquery/pkg/steam"
+	"google.golang.org/api/sheets/v4"
+)
+
+// runner holds everything shared by the runs of all profiles,
+// inventories and prices are only fetched once per run
+type runner struct {
+	logger         *log.Logger
+	limiter        *ratelimit.Limiter
+	waiter         *quotaWaiter
+	steamClient    *steam.Client
+	backpackClient *backpack.Client
+
+	// the inventory filter, shared by all profiles
+	filter      *filter.Filter
+	customItems []customitems.Item
+	routes      *routes.Routes
+	// rows written by the previous run, to clear leftover rows
+	sheetState    *sheetstate.State
+	overridesFile string
+	gcloudFile    string
+	// whether --gcloud was given explicitly, it then takes precedence over the config
+	gcloudFlagSet bool
+
+	inventories map[uint64]steam.SteamInventoryResponse
+	prices      map[priceKey]float64
+}
+
+type priceKey struct {
+	marketHashName string
+	currency       string
+	medianTime     uint
+}
+
+// run fetches, prices and writes the items of a single profile
+func (r *runner) run(cfg *config.Config) error {
+	currencySign, err := steam.GetCurrencySignByCode(cfg.Currency)
+	if err != nil {
+		return fmt.Errorf("getting currency sign for %s: %w", cfg.Currency, err)
+	}
+
+	r.logger.Debug("will use currency sign: %s", currencySign)
+
+	inv, err := r.inventory(cfg.SteamUserID64)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Debug("unfiltered inventory response: %d item(s)", len(inv.Descriptions))
+
+	inv = r.filter.Apply(inv, r.logger)
+
+	r.logger.Debug("filtered inventory response: %d item(s)", len(inv.Descriptions))
+
+	amountMap := filter.GetItemAmountMap(inv)
+
+	r.logger.Debug("got amount map: %d item(s)", len(amountMap))
+
+	itemsAmountMap := customitems.AddAmounts(amountMap, r.customItems)
+
+	r.logger.Debug("added items to amount map: total: %d item(s)", len(itemsAmountMap))
+
+	priceOverrides, err := overrides.Load(r.overridesFile)
+	if err != nil {
+		return fmt.Errorf("loading price overrides: %w", err)
+	}
+
+	for _, item := range r.customItems {
+		if item.Price == nil {
+			continue
+		}
+		// Entries of the same item share their price, see customitems.Load.
+		if o, ok := priceOverrides.Lookup(item.MarketHashName); ok && o.Price == *item.Price {
+			continue
+		}
+		if err := priceOverrides.Add(overrides.Override{
+			MarketHashName: item.MarketHashName,
+			Price:          *item.Price,
+		}); err != nil {
+			return fmt.Errorf("adding price of items file: %w (price is also set in overrides file)", err)
+		}
+	}
+
+	if err := priceOverrides.CheckCurrency(cfg.Currency); err != nil {
+		return fmt.Errorf("checking price overrides: %w", err)
+	}
+
+	r.logger.Debug("loaded price overrides: %d item(s)", priceOverrides.Len())
+
+	items, err := r.priceItems(cfg, itemsAmountMap, priceOverrides)
+	if err != nil {
+		return err
+	}
+
+	purchases := customitems.Purchases(r.customItems)
+	for i := range items {
+		items[i].Purchase = purchases[items[i].MarketHashName]
+	}
+
+	outputs := r.route(cfg, inv, items)
+
+	sheetsSvc, err := r.spreadsheetService(cfg)
+	if err != nil {
+		return fmt.Errorf("creating spreadsheet service: %w", err)
+	}
+
+	r.logger.Debug("successfully created spreadsheet service")
+
+	r.logger.Info("Fetching pre run data from spreadsheet...")
+
+	// All reads of a run are batched into a single request, the writes are batched by tables.Apply.
+	reads := preRunRanges(cfg)
+	totalReads := make([]int, len(outputs))
+	for i, out := range outputs {
+		totalReads[i] = -1
+		if rng := out.totalRange(); rng != "" {
+			totalReads[i] = len(reads)
+			reads = append(reads, rng)
+		}
+	}
+	// sheets besides the main one, which have to exist
+	var extraSheets []string
+	summaryRead := -1
+	if cfg.SummaryGroupBy != "" {
+		summaryRead = len(reads)
+		reads = append(reads, summaryRange(cfg))
+		extraSheets = append(extraSheets, cfg.SummarySheet)
+	}
+	historyRead := -1
+	if cfg.HistorySheet != "" {
+		historyRead = len(reads)
+		reads = append(reads, historyRange(cfg))
+		extraSheets = append(extraSheets, cfg.HistorySheet)
+	}
+	priceHistoryRead := -1
+	if cfg.PriceHistorySheet != "" {
+		priceHistoryRead = len(reads)
+		reads = append(reads, priceHistoryRanges(cfg)...)
+		extraSheets = append(extraSheets, cfg.PriceHistorySheet)
+	}
+
+	batchRead := sheetsSvc.BatchRead
+	if cfg.NumericPrices {
+		batchRead = sheetsSvc.BatchReadUnformatted
+	}
+
+	values, err := batchRead(reads...)
+	if err != nil {
+		if len(extraSheets) > 0 {
+			return fmt.Errorf(
+				"fetching pre run data (make sure the sheets %s exist): %w",
+				strings.Join(extraSheets, ", "),
+				err,
+			)
+		}
+		return fmt.Errorf("fetching pre run data: %w", err)
+	}
+
+	preRunData, err := parsePreRunData(values, cfg, currencySign)
+	if err != nil {
+		return fmt.Errorf("fetching pre run data: %w", err)
+	}
+
+	r.logger.Info("Successfully fetched pre run data from spreadsheet")
+
+	if preRunData.Error != lastRunNoError {
+		r.logger.Warn("Last run error: %s", preRunData.Error)
+	}
+
+	if time.Since(preRunData.LastUpdated) < lastUpdateCooldown {
+		r.logger.Warn(
+			"Last update was less than %s ago, please refrain from spamming",
+			lastUpdateCooldown.String(),
+		)
+	}
+
+	var data []*sheets.ValueRange
+	var stale []string
+	// rows to record in the sheet state once they are written
+	written := make(map[string]sheetstate.Rows)
+	var formats []tables.NumberFormat
+	// rows and columns of history sheets dropped by retention
+	var deleted []tables.Rows
+	var deletedColumns []tables.Columns
+
+	newTotal := 0.0
+	for i, out := range outputs {
+		var preTotal float64
+		if totalReads[i] >= 0 {
+			preTotal, err = parsePrice(firstValue(values[totalReads[i]]), cfg, currencySign)
+			if err != nil {
+				return fmt.Errorf("destination %s: parsing total value cell: %w", out.destination.Name, err)
+			}
+		}
+
+		built := r.buildOutput(cfg, currencySign, out, preTotal)
+		data = append(data, built.data...)
+		formats = append(formats, built.formats...)
+		staleRows, writtenRows := staleRanges(r.sheetState, cfg, out.destination, built)
+		stale = append(stale, staleRows...)
+		for key, rows := range writtenRows {
+			written[key] = rows
+		}
+		newTotal += built.total
+	}
+
+	if summaryRead >= 0 {
+		summary, summaryFormats := r.buildSummary(cfg, currencySign, descriptionsByName(inv), items, values[summaryRead])
+		data = append(data, summary)
+		formats = append(formats, summaryFormats...)
+	}
+
+	difference := newTotal - preRunData.Total
+
+	if historyRead >= 0 {
+		itemCount := 0
+		for _, item := range items {
+			itemCount += item.Amount
+		}
+
+		history, historyFormats, historyDeleted := buildHistory(cfg, currencySign, values[historyRead], historyEntry{
+			time:       time.Now(),
+			total:      newTotal,
+			difference: difference,
+			itemCount:  itemCount,
+			currency:   cfg.Currency,
+			source:     priceSource(cfg, items, r.customItems),
+		})
+		data = append(data, history)
+		formats = append(formats, historyFormats...)
+		deleted = append(deleted, historyDeleted...)
+	}
+
+	if priceHistoryRead >= 0 {
+		priceHistory := buildPriceHistory(
+			cfg,
+			currencySign,
+			values[priceHistoryRead:priceHistoryRead+len(priceHistoryRanges(cfg))],
+			time.Now(),
+			items,
+		)
+		data = append(data, priceHistory.Values...)
+		formats = append(formats, priceHistory.Formats...)
+		deleted = append(deleted, priceHistory.DeleteRows...)
+		deletedColumns = append(deletedColumns, priceHistory.DeleteColumns...)
+	}
+
+	data = append(
+		data,
+		cellValue(cfg.TotalValueCell, cfg.TotalValueCell, priceValue(cfg, currencySign, newTotal)),
+		cellValue(cfg.DifferenceCell, cfg.DifferenceCell, priceValue(cfg, currencySign, difference)),
+		cellValue(cfg.ErrorCell, cfg.ErrorCell, lastRunNoError),
+		cellValue(cfg.LastUpdatedCell, cfg.LastUpdatedCell, time.Now().Format(lastUpdatedFormat)),
+	)
+
+	formats = append(
+		formats,
+		priceFormat("", cfg.TotalValueCell, cfg.TotalValueCell, currencySign),
+		priceFormat("", cfg.DifferenceCell, cfg.DifferenceCell, currencySign),
+	)
+
+	update := tables.Update{DeleteRows: deleted, DeleteColumns: deletedColumns, Values: data, Clear: stale}
+	for _, f := range formats {
+		// Prices and percentages are text unless in numeric mode, timestamps are always numbers.
+		if cfg.NumericPrices || f.Type == dateTimeType {
+			update.Formats = append(update.Formats, f)
+		}
+	}
+
+	r.logger.Info("Writing data to spreadsheet...")
+
+	// Leftover rows are cleared along with writing the new rows, either all changes are made or none.
+	if err := sheetsSvc.Apply(update); err != nil {
+		return fmt.Errorf("writing data: %w", err)
+	}
+
+	r.logger.Debug(
+		"deleted %d row and %d column range(s), wrote %d range(s), cleared %d leftover range(s): %v, applied %d number format(s)",
+		len(update.DeleteRows),
+		len(update.DeleteColumns),
+		len(update.Values),
+		len(update.Clear),
+		update.Clear,
+		len(update.Formats),
+	)
+
+	// A failed write must not be recorded, later profiles would save it otherwise.
+	for key, rows := range written {
+		r.sheetState.SetRows(key, rows)
+	}
+	if err := r.sheetState.Save(); err != nil {
+		return err
+	}
+
+	r.logger.Info("Wrote data to spreadsheet")
+
+	return nil
+}
+
+// spreadsheetService uses the explicit --gcloud flag, then google_credentials
+// of the config (a path or, e.g. resolved from a secret, the credentials JSON itself),
+// then the --gcloud default
+func (r *runner) spreadsheetService(cfg *config.Config) (*tables.SpreadsheetService, error) {
+	if r.gcloudFlagSet || cfg.GoogleCredentials == "" {
+		return tables.NewSpreadsheetService(r.gcloudFile, cfg.SpreadSheetID)
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(cfg.GoogleCredentials), "{") {
+		return tables.NewSpreadsheetServiceFromJSON([]byte(cfg.GoogleCredentials), cfg.SpreadSheetID)
+	}
+
+	return tables.NewSpreadsheetService(cfg.GoogleCredentials, cfg.SpreadSheetID)
+}
+
+// inventory returns the inventory of the given user, fetching it only once per run
+func (r *runner) inventory(steamID uint64) (steam.SteamInventoryResponse, error) {
+	if inv, ok := r.inventories[steamID]; ok {
+		r.logger.Debug("using already fetched inventory for user %d", steamID)
+		return inv, nil
+	}
+
+	r.logger.Info("Fetching inventory...")
+
+	inv, err := r.steamClient.GetInventory(steamID)
+	if err != nil {
+		return steam.SteamInventoryResponse{}, fmt.Errorf("getting inventory: %w", err)
+	}
+
+	if err := r.limiter.Save(); err != nil {
+		return steam.SteamInventoryResponse{}, fmt.Errorf("saving rate limit state: %w", err)
+	}
+
+	r.logger.Debug("queried inventory for user %d", steamID)
+	r.logger.Info("Successfully got inventory")
+
+	r.inventories[steamID] = inv
+
+	return inv, nil
+}
+
+// priceItems prices all items by override, by an already fetched price or from csgobackpack
+func (r *runner) priceItems(
+	cfg *config.Config,
+	itemsAmountMap map[string]int,
+	priceOverrides *overrides.Set,
+) ([]inventoryItem, error) {
+	now := time.Now()
+	items := make([]inventoryItem, 0, len(itemsAmountMap))
+	toFetch := make(map[string]int)
+
+	for marketHashName, amount := range itemsAmountMap {
+		if o, ok := priceOverrides.Lookup(marketHashName); ok {
+			if !o.Expired(now) {
+				r.logger.Info("Using price override for %s: %.2f", marketHashName, o.Price)
+				items = append(items, inventoryItem{
+					MarketHashName: marketHashName,
+					Amount:         amount,
+					Price:          o.Price,
+					Overridden:     true,
+				})
+				continue
+			}
+			r.logger.Warn("Price override for %s expired on %s, using market price", marketHashName, o.Expires)
+		}
+
+		key := priceKey{marketHashName, cfg.Currency, cfg.MedianPriceDays}
+		if price, ok := r.prices[key]; ok {
+			items = append(items, inventoryItem{
+				MarketHashName: marketHashName,
+				Amount:         amount,
+				Price:          price,
+			})
+			continue
+		}
+
+		toFetch[marketHashName] = amount
+	}
+
+	if len(toFetch) == 0 {
+		return items, nil
+	}
+
+	if !r.backpackClient.IsAvailable() {
+		return nil, fmt.Errorf("csgobackpack is currently unavailable, retry later")
+	}
+
+	r.logger.Info("Fetching item prices...")
+
+	if err := r.waiter.waitFor(backpackBucket, len(toFetch)); err != nil {
+		return nil, fmt.Errorf("rate limit exceeded: %w", err)
+	}
+
+	r.logger.Debug("backpack rate limit not exceeded, continuing")
+
+	for marketHashName, amount := range toFetch {
+		price, err := r.backpackClient.GetItemPrice(
+			marketHashName,
+			&backpack.RequestOptions{
+				MedianTime: cfg.MedianPriceDays,
+				Currency:   cfg.Currency,
+			},
+		)
+		if err != nil {
+			if err != backpack.ZeroPriceError {
+				return nil, fmt.Errorf("getting item price: %w", err)
+			}
+			price = 0.0
+			r.logger.Warn("Item currently has no price: %s", marketHashName)
+		}
+
+		r.prices[priceKey{marketHashName, cfg.Currency, cfg.MedianPriceDays}] = price
+
+		items = append(items, inventoryItem{
+			MarketHashName: marketHashName,
+			Amount:         amount,
+			Price:          price,
+		})
+	}
+
+	r.logger.Debug("got item prices: %d item(s)", len(items))
+	r.logger.Info("Successfully fetched item prices")
+
+	if err := r.limiter.Save(); err != nil {
+		return nil, fmt.Errorf("saving rate limit state: %w", err)
+	}
+
+	return items, nil
+}
+
+type inventoryItem struct {
+	MarketHashName string
+	Amount         int
+	Price          float64
+	Overridden     bool
+	// Purchase is set for items of the items file with purchase information
+	Purchase customitems.Purchase
+	// Other marks the row collapsing all items removed by value filters,
+	// its price is the average unit price
+	Other bool
+}
+
+func (i inventoryItem) Name() string {
+	return i.MarketHashName
+}
+
+func (i inventoryItem) UnitValue() float64 {
+	return i.Price
+}
+
+func (i inventoryItem) TotalValue() float64 {
+	return i.Price * float64(i.Amount)
+}
+
+// collapseOther sums up the items into a single "Other (N items)" row
+func collapseOther(items []inventoryItem) inventoryItem {
+	other := inventoryItem{
+		MarketHashName: fmt.Sprintf("Other (%d items)", len(items)),
+		Other:          true,
+	}
+
+	total := 0.0
+	for _, item := range items {
+		other.Amount += item.Amount
+		total += item.TotalValue()
+	}
+	if other.Amount > 0 {
+		other.Price = total / float64(other.Amount)
+	}
+
+	return other
+}
+
+type preRunData struct {
+	LastUpdated time.Time
+	Error       string
+	Total       float64
+}
+
+const (
+	lastUpdatedFormat  = time.RFC3339Nano
+	lastUpdateCooldown = 5 * time.Minute
+	lastRunNoError     = "No error occured."
+	overrideMarker     = "override"
+)
+
+// preRunRanges returns the ranges of the last updated, error and total value cells
+func preRunRanges(cfg *config.Config) []string {
+	return []string{
+		tables.Range(cfg.LastUpdatedCell, cfg.LastUpdatedCell),
+		tables.Range(cfg.ErrorCell, cfg.ErrorCell),
+		tables.Range(cfg.TotalValueCell, cfg.TotalValueCell),
+	}
+}
+
+// parsePreRunData parses the value ranges read for preRunRanges
+func parsePreRunData(values []*sheets.ValueRange, cfg *config.Config, currencySign string) (*preRunData, error) {
+	var lu time.Time
+	if v := firstValue(values[0]); v != nil && fmt.Sprint(v) != "" {
+		var err error
+		lu, err = time.Parse(lastUpdatedFormat, fmt.Sprint(v))
+		if err != nil {
+			return nil, fmt.Errorf("parsing last updated cell: %w", err)
+		}
+	}
+
+	var errStr string
+	if v := firstValue(values[1]); v != nil {
+		errStr = fmt.Sprint(v)
+	}
+
+	total, err := parsePrice(firstValue(values[2]), cfg, currencySign)
+	if err != nil {
+		return nil, fmt.Errorf("parsing total value cell: %w", err)
+	}
+
+	return &preRunData{
+		LastUpdated: lu,
+		Error:       errStr,
+		Total:       total,
+	}, nil
+}
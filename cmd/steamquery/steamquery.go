@@ -4,20 +4,18 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"slices"
-	"sort"
-	"strconv"
 	"time"
 
 	"github.com/devusSs/steamquery/internal/backpack"
-	bratelimit "github.com/devusSs/steamquery/internal/backpack/ratelimit"
+	"github.com/devusSs/steamquery/internal/catalogue"
 	"github.com/devusSs/steamquery/internal/config"
-	"github.com/devusSs/steamquery/internal/format"
+	"github.com/devusSs/steamquery/internal/customitems"
+	"github.com/devusSs/steamquery/internal/routes"
+	"github.com/devusSs/steamquery/internal/sheetstate"
 	"github.com/devusSs/steamquery/internal/steam/filter"
-	"github.com/devusSs/steamquery/internal/steam/ratelimit"
-	sratelimit "github.com/devusSs/steamquery/internal/steam/ratelimit"
-	"github.com/devusSs/steamquery/internal/tables"
 	"github.com/devusSs/steamquery/internal/updater"
 	"github.com/devusSs/steamquery/pkg/log"
 	"github.com/devusSs/steamquery/pkg/steam"
@@ -38,6 +36,14 @@ func main() {
 	var filterFileFlag *string = flag.StringP("filter", "f", "", "Path to filter file if desired, empty uses default filter")
 	var itemsFileFlag *string = flag.StringP("items", "i", "", "Path to additional items file if desired, empty uses raw inventory")
 	var gcloudFileFlag *string = flag.StringP("gcloud", "g", ".gcloud.json", "Path to Google credentials file")
+	var routesFileFlag *string = flag.StringP("routes", "r", "", "Path to routes file if desired, empty writes all items to the config layout")
+	var overridesFileFlag *string = flag.StringP("overrides", "o", "", "Path to price overrides file if desired, empty uses market prices only")
+	var profileFlag *string = flag.StringP("profile", "p", "", "Name of the config profile to use, empty uses the common config fields only")
+	var allProfilesFlag *bool = flag.Bool("all-profiles", false, "Run all config profiles back to back")
+	var setFlag *[]string = flag.StringArray("set", nil, "Override a config field, e.g. --set currency=USD (repeatable)")
+	var noNameCheckFlag *bool = flag.Bool("no-name-check", false, "Disable checking the items file names against the known item names")
+	var waitFlag *bool = flag.Bool("wait", false, "Wait for rate limit budget to free up instead of exiting")
+	var maxWaitFlag *time.Duration = flag.Duration("max-wait", 15*time.Minute, "Maximum time to wait for rate limit budget (requires --wait)")
 	flag.Parse()
 
 	if *helpFlag {
@@ -55,6 +61,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	if flag.NArg() > 0 {
+		opts := &commandOptions{
+			logsDir:     *logsDirFlag,
+			configFile:  *configFileFlag,
+			gcloudFile:  *gcloudFileFlag,
+			profile:     *profileFlag,
+			allProfiles: *allProfilesFlag,
+			overrides:   *setFlag,
+			itemsFile:   *itemsFileFlag,
+			noNameCheck: *noNameCheckFlag,
+		}
+		if err := runCommand(opts, flag.Args()); err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if !*noUpdateFlag {
 		if err := updater.CheckForUpdatesAndApply(buildVersion); err != nil {
 			fmt.Printf("Error updating: %s\n", err.Error())
@@ -73,332 +97,230 @@ func main() {
 
 	logger.Info("App start")
 
-	sratelimit.SetRateLimitConfig(*logsDirFlag, 15, time.Minute)
-	bratelimit.SetRateLimitConfig(*logsDirFlag, 1000, time.Hour)
-
-	state, err := sratelimit.LoadRateLimitState()
+	limiter, err := newLimiter(*logsDirFlag)
 	if err != nil {
 		logger.Error("Error loading rate limit state: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Debug("loaded steam rate limit state: %v", state)
-
-	if !sratelimit.WithinRateLimit(state, 2) {
-		logger.Error("Rate limit exceeded, retry later")
-		os.Exit(1)
+	if backup := limiter.Recovered(); backup != "" {
+		logger.Warn("Rate limit state was corrupted and has been reset, moved it to %s", backup)
 	}
 
-	logger.Debug("steam rate limit not exceeded, continuing")
+	logger.Debug(
+		"loaded rate limit state: steam: %d remaining, backpack: %d remaining",
+		limiter.Remaining(steamBucket.Name),
+		limiter.Remaining(backpackBucket.Name),
+	)
+
+	waiter := &quotaWaiter{
+		limiter:  limiter,
+		wait:     *waitFlag,
+		maxWait:  *maxWaitFlag,
+		progress: *consoleFlag || *debugFlag,
+		logger:   logger,
+	}
 
-	cfg, err := config.Load(*configFileFlag)
+	profiles, err := loadProfiles(*configFileFlag, *profileFlag, *allProfilesFlag, *setFlag)
 	if err != nil {
 		logger.Error("Error loading config: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Debug("loaded config from %s: %v", *configFileFlag, cfg)
+	for _, p := range profiles {
+		logger.Debug("loaded config %s from %s: %v", p.name, *configFileFlag, p.cfg)
+	}
 	logger.Info("Successfully loaded config file")
 
-	currencySign, err := steam.GetCurrencySignByCode(cfg.Currency)
+	invFilter, err := filter.Load(*filterFileFlag)
 	if err != nil {
-		logger.Error("Error getting currency sign for %s: %v", cfg.Currency, err)
+		logger.Error("Error loading filter file: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Debug("will use currency sign: %s", currencySign)
+	logger.Debug("loaded filter: %v", invFilter)
 
-	steamStatus, err := steam.GetSteamStatus(cfg.SteamAPIKey)
+	customItems, err := customitems.Load(*itemsFileFlag)
 	if err != nil {
-		logger.Error("Error getting Steam status: %v", err)
+		logger.Error("Error loading items file: %v", err)
 		os.Exit(1)
 	}
 
-	state.LastRequestTime = time.Now()
-	state.RequestCount++
-	if err := ratelimit.SaveRateLimitState(state); err != nil {
-		logger.Error("Error saving rate limit state: %v", err)
-		os.Exit(1)
-	}
-
-	logger.Debug("got steam status: %v", steamStatus)
+	logger.Debug("loaded items file: %d item(s)", len(customItems))
 
-	if !steamStatus.IsOnline() {
-		logger.Error("Steam services are offline, retry later")
+	itemRoutes, err := routes.Load(*routesFileFlag)
+	if err != nil {
+		logger.Error("Error loading routes file: %v", err)
 		os.Exit(1)
 	}
 
-	if steamStatus.IsDelayed() {
-		logger.Warn("Steam services are delayed, expect issues")
-	} else {
-		logger.Info("Steam services are online")
+	for _, p := range profiles {
+		if err := itemRoutes.Validate(p.cfg); err != nil {
+			logger.Error("Error validating routes file for profile %s: %v", p.name, err)
+			os.Exit(1)
+		}
 	}
 
-	logger.Info("Fetching inventory...")
+	logger.Debug("loaded routes file: %d destination(s)", len(itemRoutes.Destinations))
 
-	inv, err := steam.GetInventory(cfg.SteamUserID64)
+	sheetState, err := sheetstate.Load(filepath.Join(*logsDirFlag, sheetstate.DefaultFileName))
 	if err != nil {
-		logger.Error("Error getting inventory: %v", err)
+		logger.Error("Error loading sheet state: %v", err)
 		os.Exit(1)
 	}
 
-	state.LastRequestTime = time.Now()
-	state.RequestCount++
-	if err := ratelimit.SaveRateLimitState(state); err != nil {
-		logger.Error("Error saving rate limit state: %v", err)
+	if err := waiter.waitFor(steamBucket, steamRequests(profiles)); err != nil {
+		logger.Error("Rate limit exceeded: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Debug("queried inventory for user %d", cfg.SteamUserID64)
-	logger.Info("Successfully got inventory")
+	logger.Debug("steam rate limit not exceeded, continuing")
 
-	logger.Debug("unfiltered inventory response: %d item(s)", len(inv.Descriptions))
+	steamClient := steam.NewClient(steam.WithLimiter(limiter.Bucket(steamBucket.Name)))
+	backpackClient := backpack.NewClient(backpack.WithLimiter(limiter.Bucket(backpackBucket.Name)))
 
-	if err := filter.LoadFilterOptions(*filterFileFlag); err != nil {
-		logger.Error("Error loading filter options: %v", err)
-		os.Exit(1)
+	if nameCheckEnabled(customItems, *noNameCheckFlag) {
+		if err := checkItemNames(customItems, *logsDirFlag, waiter, backpackClient, logger); err != nil {
+			logger.Error("Error checking items file: %v", err)
+			os.Exit(1)
+		}
 	}
 
-	logger.Debug("loaded filter options: %v", filter.GetFilterSettings())
-
-	inv = filter.FilterInventoryResponse(inv)
-
-	logger.Debug("filtered inventory response: %d item(s)", len(inv.Descriptions))
-
-	amountMap := filter.GetItemAmountMap(inv)
-
-	logger.Debug("got amount map: %d item(s)", len(amountMap))
-
-	itemsAmountMap, err := filter.AddItems(amountMap, *itemsFileFlag)
+	steamStatus, err := steamClient.GetSteamStatus(profiles[0].cfg.SteamAPIKey)
 	if err != nil {
-		logger.Error("Adding additional items failed: %v", err)
+		logger.Error("Error getting Steam status: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Debug("added items to amount map: total: %d item(s)", len(itemsAmountMap))
-
-	if !backpack.IsAvailable() {
-		logger.Error("csgobackpack is currently unavailable, retry later")
+	if err := limiter.Save(); err != nil {
+		logger.Error("Error saving rate limit state: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Info("Fetching item prices...")
+	logger.Debug("got steam status: %v", steamStatus)
 
-	bstate, err := bratelimit.LoadRateLimitState()
-	if err != nil {
-		logger.Error("Error loading rate limit state: %v", err)
+	if !steamStatus.IsOnline() {
+		logger.Error("Steam services are offline, retry later")
 		os.Exit(1)
 	}
 
-	logger.Debug("loaded backpack rate limit state: %v", bstate)
-
-	if !bratelimit.WithinRateLimit(bstate, len(amountMap)) {
-		logger.Error("Rate limit exceeded, retry later")
-		os.Exit(1)
+	if steamStatus.IsDelayed() {
+		logger.Warn("Steam services are delayed, expect issues")
+	} else {
+		logger.Info("Steam services are online")
 	}
 
-	logger.Debug("backpack rate limit not exceeded, continuing")
-
-	items := make([]inventoryItem, 0, len(itemsAmountMap))
-	for marketHashName, amount := range itemsAmountMap {
-		price, err := backpack.GetItemPrice(
-			marketHashName,
-			&backpack.RequestOptions{
-				MedianTime: cfg.MedianPriceDays,
-				Currency:   cfg.Currency,
-			},
-		)
-		if err != nil {
-			if err != backpack.ZeroPriceError {
-				logger.Error("Error getting item price: %v", err)
-				os.Exit(1)
-			}
-			price = 0.0
-			logger.Warn("Item currently has no price: %s", marketHashName)
+	r := &runner{
+		logger:         logger,
+		limiter:        limiter,
+		waiter:         waiter,
+		steamClient:    steamClient,
+		backpackClient: backpackClient,
+		filter:         invFilter,
+		customItems:    customItems,
+		routes:         itemRoutes,
+		sheetState:     sheetState,
+		overridesFile:  *overridesFileFlag,
+		gcloudFile:     *gcloudFileFlag,
+		gcloudFlagSet:  flag.CommandLine.Changed("gcloud"),
+		inventories:    make(map[uint64]steam.SteamInventoryResponse),
+		prices:         make(map[priceKey]float64),
+	}
+
+	failed := 0
+	for _, p := range profiles {
+		if len(profiles) > 1 {
+			logger.Info("Running profile %s", p.name)
 		}
-		item := inventoryItem{
-			MarketHashName: marketHashName,
-			Amount:         amount,
-			Price:          price,
+		if err := r.run(p.cfg); err != nil {
+			logger.Error("Error running profile %s: %v", p.name, err)
+			failed++
 		}
-		items = append(items, item)
-
-		bstate.LastRequestTime = time.Now()
-		bstate.RequestCount++
 	}
 
-	logger.Debug("got item prices: %d item(s)", len(items))
-	logger.Info("Successfully fetched item prices")
+	logger.Info("App exit")
 
-	if err := bratelimit.SaveRateLimitState(bstate); err != nil {
-		logger.Error("Error saving rate limit state: %v", err)
-		os.Exit(1)
-	}
+	logger.Debug("run took %v", time.Since(startTime))
 
-	sheetsSvc, err := tables.NewSpreadsheetService(*gcloudFileFlag, cfg.SpreadSheetID)
-	if err != nil {
-		logger.Error("Error creating spreadsheet service: %v", err)
+	if failed > 0 {
 		os.Exit(1)
 	}
+}
 
-	logger.Debug("successfully created spreadsheet service")
-
-	if err := sheetsSvc.Test(); err != nil {
-		logger.Error("Error testing spreadsheet connection: %v", err)
-		os.Exit(1)
+// checkItemNames checks the items file names against the (cached) csgobackpack item list,
+// if the list is unavailable the check is skipped
+func checkItemNames(
+	items []customitems.Item,
+	logsDir string,
+	waiter *quotaWaiter,
+	backpackClient *backpack.Client,
+	logger *log.Logger,
+) error {
+	cat, fetched, err := catalogue.Load(
+		filepath.Join(logsDir, catalogue.DefaultFileName),
+		catalogue.DefaultMaxAge,
+		func() ([]string, error) {
+			if err := waiter.waitFor(backpackBucket, 1); err != nil {
+				return nil, err
+			}
+			return backpackClient.GetItemNames()
+		},
+	)
+	if fetched {
+		if saveErr := waiter.limiter.Save(); saveErr != nil {
+			return fmt.Errorf("saving rate limit state: %w", saveErr)
+		}
 	}
-
-	logger.Debug("successfully tested spreadsheet connection")
-
-	logger.Info("Fetching pre run data from spreadsheet...")
-
-	preRunData, err := fetchPreRunData(sheetsSvc, cfg, currencySign)
 	if err != nil {
-		logger.Error("Error fetching pre run data: %v", err)
-		os.Exit(1)
-	}
-
-	logger.Info("Successfully fetched pre run data from spreadsheet")
-
-	if preRunData.Error != lastRunNoError {
-		logger.Warn("Last run error: %s", preRunData.Error)
-	}
-
-	if time.Since(preRunData.LastUpdated) < lastUpdateCooldown {
-		logger.Warn(
-			"Last update was less than %s ago, please refrain from spamming",
-			lastUpdateCooldown.String(),
-		)
-	}
-
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].MarketHashName < items[j].MarketHashName
-	})
-
-	startRow := cfg.StartingRow
-	endRow := startRow + uint(len(items))
-
-	logger.Debug("will write to rows %d-%d", startRow, endRow)
-
-	logger.Info("Writing data to spreadsheet...")
-
-	itemsData := make([][]interface{}, 0, len(items))
-	for _, item := range items {
-		itemsData = append(itemsData, []interface{}{item.MarketHashName})
-	}
-
-	if err := sheetsSvc.Write(
-		fmt.Sprintf("%s%d", cfg.ItemColumn, startRow),
-		fmt.Sprintf("%s%d", cfg.ItemColumn, endRow),
-		itemsData,
-	); err != nil {
-		logger.Error("Error writing items: %v", err)
-		os.Exit(1)
-	}
-
-	logger.Debug("wrote items")
-
-	amountData := make([][]interface{}, 0, len(items))
-	for _, item := range items {
-		amountData = append(amountData, []interface{}{item.Amount})
-	}
-
-	if err := sheetsSvc.Write(
-		fmt.Sprintf("%s%d", cfg.AmountColumn, startRow),
-		fmt.Sprintf("%s%d", cfg.AmountColumn, endRow),
-		amountData,
-	); err != nil {
-		logger.Error("Error writing amounts: %v", err)
-		os.Exit(1)
-	}
-
-	logger.Debug("wrote amounts")
-
-	singlePriceData := make([][]interface{}, 0, len(items))
-	for _, item := range items {
-		singlePriceStr := format.FormatPricePrintable(
-			item.Price,
-			cfg.DecimalSeparator,
-			currencySign,
-		)
-		singlePriceData = append(singlePriceData, []interface{}{singlePriceStr})
-	}
-
-	if err := sheetsSvc.Write(
-		fmt.Sprintf("%s%d", cfg.SinglePriceColumn, startRow),
-		fmt.Sprintf("%s%d", cfg.SinglePriceColumn, endRow),
-		singlePriceData,
-	); err != nil {
-		logger.Error("Error writing single prices: %v", err)
-		os.Exit(1)
+		logger.Warn("Could not check items file names: %v", err)
+		return nil
 	}
 
-	logger.Debug("wrote single prices")
+	logger.Debug("loaded %d known item names (fetched: %t)", cat.Len(), fetched)
 
-	totalPriceData := make([][]interface{}, 0, len(items))
-	for _, item := range items {
-		totalPriceStr := format.FormatPricePrintable(
-			item.Price*float64(item.Amount),
-			cfg.DecimalSeparator,
-			currencySign,
-		)
-		totalPriceData = append(totalPriceData, []interface{}{totalPriceStr})
-	}
-
-	if err := sheetsSvc.Write(
-		fmt.Sprintf("%s%d", cfg.TotalPriceColumn, startRow),
-		fmt.Sprintf("%s%d", cfg.TotalPriceColumn, endRow),
-		totalPriceData,
-	); err != nil {
-		logger.Error("Error writing total prices: %v", err)
-		os.Exit(1)
-	}
-
-	logger.Debug("wrote total prices")
-
-	newTotal := 0.0
-	for _, item := range items {
-		newTotal += item.Price * float64(item.Amount)
-	}
-
-	difference := newTotal - preRunData.Total
-
-	totalStr := format.FormatPricePrintable(newTotal, cfg.DecimalSeparator, currencySign)
-	differenceStr := format.FormatPricePrintable(difference, cfg.DecimalSeparator, currencySign)
-
-	if err := sheetsSvc.Write(cfg.TotalValueCell, cfg.TotalValueCell, [][]interface{}{{totalStr}}); err != nil {
-		logger.Error("Error writing total value cell: %v", err)
-		os.Exit(1)
-	}
+	return customitems.CheckNames(items, cat.Lookup)
+}
 
-	logger.Debug("wrote total value cell")
+type profile struct {
+	name string
+	cfg  *config.Config
+}
 
-	if err := sheetsSvc.Write(cfg.DifferenceCell, cfg.DifferenceCell, [][]interface{}{{differenceStr}}); err != nil {
-		logger.Error("Error writing difference cell: %v", err)
-		os.Exit(1)
+// loadProfiles loads the selected profile (or none), or all profiles of the config file
+func loadProfiles(path string, name string, all bool, overrides []string) ([]profile, error) {
+	if all && name != "" {
+		return nil, fmt.Errorf("--profile and --all-profiles cannot be used together")
 	}
 
-	logger.Debug("wrote difference cell")
-
-	if err := sheetsSvc.Write(cfg.ErrorCell, cfg.ErrorCell, [][]interface{}{{lastRunNoError}}); err != nil {
-		logger.Error("Error writing error cell: %v", err)
-		os.Exit(1)
+	names := []string{name}
+	if all {
+		var err error
+		names, err = config.Profiles(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no profiles found in %s", path)
+		}
 	}
 
-	logger.Debug("wrote error cell")
+	profiles := make([]profile, 0, len(names))
+	for _, name := range names {
+		cfg, err := config.Load(path, config.WithProfile(name), config.WithOverrides(overrides))
+		if err != nil {
+			if name != "" {
+				return nil, fmt.Errorf("profile \"%s\": %w", name, err)
+			}
+			return nil, err
+		}
 
-	if err := sheetsSvc.Write(cfg.LastUpdatedCell, cfg.LastUpdatedCell, [][]interface{}{{time.Now().Format(lastUpdatedFormat)}}); err != nil {
-		logger.Error("Error writing total value cell: %v", err)
-		os.Exit(1)
+		if name == "" {
+			name = "default"
+		}
+		profiles = append(profiles, profile{name: name, cfg: cfg})
 	}
 
-	logger.Debug("wrote last updated cell")
-
-	logger.Info("Wrote data to spreadsheet")
-
-	logger.Info("App exit")
-
-	logger.Debug("run took %v", time.Since(startTime))
+	return profiles, nil
 }
 
 const (
@@ -423,6 +345,8 @@ func init() {
 	}
 
 	flag.CommandLine.SortFlags = true
+	// Commands parse their own flags.
+	flag.CommandLine.SetInterspersed(false)
 	flag.Usage = printHelp
 }
 
@@ -431,6 +355,10 @@ func printHelp() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  ./steamquery [flags]")
+	fmt.Println("  ./steamquery [flags] <command>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	printCommands()
 	fmt.Println()
 	fmt.Println("Flags:")
 	flag.PrintDefaults()
@@ -481,74 +409,3 @@ func checkOSAndArchComp() error {
 
 	return nil
 }
-
-type inventoryItem struct {
-	MarketHashName string
-	Amount         int
-	Price          float64
-}
-
-type preRunData struct {
-	LastUpdated time.Time
-	Error       string
-	Total       float64
-}
-
-const (
-	lastUpdatedFormat  = time.RFC3339Nano
-	lastUpdateCooldown = 5 * time.Minute
-	lastRunNoError     = "No error occured."
-)
-
-func fetchPreRunData(
-	svc *tables.SpreadsheetService,
-	cfg *config.Config,
-	currencySign string,
-) (*preRunData, error) {
-	luRaw, err := svc.Read(cfg.LastUpdatedCell, cfg.LastUpdatedCell)
-	if err != nil {
-		return nil, fmt.Errorf("getting last updated cell: %w", err)
-	}
-
-	var lu time.Time
-	if len(luRaw.Values) > 0 {
-		lu, err = time.Parse(lastUpdatedFormat, luRaw.Values[0][0].(string))
-		if err != nil {
-			return nil, fmt.Errorf("parsing last updated cell: %w", err)
-		}
-	}
-
-	errRaw, err := svc.Read(cfg.ErrorCell, cfg.ErrorCell)
-	if err != nil {
-		return nil, fmt.Errorf("getting error cell: %w", err)
-	}
-
-	var errStr string
-	if len(errRaw.Values) > 0 {
-		errStr = errRaw.Values[0][0].(string)
-	}
-
-	totalRaw, err := svc.Read(cfg.TotalValueCell, cfg.TotalValueCell)
-	if err != nil {
-		return nil, fmt.Errorf("getting total value cell: %w", err)
-	}
-
-	var total float64
-	if len(totalRaw.Values) > 0 {
-		totalStr := format.FormatPriceCalculatable(
-			totalRaw.Values[0][0].(string),
-			cfg.DecimalSeparator,
-			currencySign,
-		)
-		total, err = strconv.ParseFloat(totalStr, 64)
-		if err != nil {
-			return nil, fmt.Errorf("parsing total value cell: %w", err)
-		}
-	}
-
-	return &preRunData{
-		LastUpdated: lu,
-		Error:       errStr,
-		Total:       total,
-	}, nil
-}
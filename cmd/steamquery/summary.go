@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/steam/filter"
+	"github.com/devusSs/steamquery/internal/tables"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	// summaryUnknown groups items without a tag of the chosen dimension
+	summaryUnknown = "Unknown"
+	// summaryInventory and summaryItemsFile are the locations of items without an explicit one
+	summaryInventory = "Inventory"
+	summaryItemsFile = "Items file"
+)
+
+var summaryHeader = []interface{}{"Group", "Count", "Total value", "Share", "Change"}
+
+type summaryRow struct {
+	group    string
+	count    int
+	total    float64
+	previous float64
+}
+
+// summaryRange returns the range of the previous summary totals
+func summaryRange(cfg *config.Config) string {
+	return tables.Range(tables.Cell(cfg.SummarySheet, "A2"), "C")
+}
+
+// buildSummary aggregates all priced items (before value filters) by cfg.SummaryGroupBy
+// and returns the summary to write, the change is relative to the previous summary totals
+func (r *runner) buildSummary(
+	cfg *config.Config,
+	currencySign string,
+	descs descriptions,
+	items []inventoryItem,
+	previous *sheets.ValueRange,
+) (*sheets.ValueRange, []tables.NumberFormat) {
+	rows := make(map[string]*summaryRow)
+	row := func(group string) *summaryRow {
+		if _, ok := rows[group]; !ok {
+			rows[group] = &summaryRow{group: group}
+		}
+		return rows[group]
+	}
+
+	grandTotal := 0.0
+	for _, item := range items {
+		for group, amount := range r.groupItem(cfg.SummaryGroupBy, descs, item) {
+			row := row(group)
+			row.count += amount
+			row.total += item.Price * float64(amount)
+		}
+		grandTotal += item.TotalValue()
+	}
+
+	// Groups which disappeared since the last run are kept with a total of 0.
+	for _, values := range previous.Values {
+		if len(values) < 3 || fmt.Sprint(values[0]) == "" {
+			continue
+		}
+		total, err := parsePrice(values[2], cfg, currencySign)
+		if err != nil {
+			r.logger.Warn("Could not parse previous summary total of %v: %v", values[0], err)
+			continue
+		}
+		row(fmt.Sprint(values[0])).previous = total
+	}
+
+	sorted := make([]*summaryRow, 0, len(rows))
+	for _, row := range rows {
+		// Groups which already disappeared before the last run are dropped.
+		if row.count == 0 && row.total == 0 && row.previous == 0 {
+			continue
+		}
+		sorted = append(sorted, row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].total != sorted[j].total {
+			return sorted[i].total > sorted[j].total
+		}
+		return sorted[i].group < sorted[j].group
+	})
+
+	data := make([][]interface{}, 0, len(sorted)+1)
+	data = append(data, summaryHeader)
+	for _, row := range sorted {
+		share := 0.0
+		if grandTotal > 0 {
+			share = row.total / grandTotal * 100
+		}
+		data = append(data, []interface{}{
+			row.group,
+			row.count,
+			priceValue(cfg, currencySign, row.total),
+			percentValue(cfg, share),
+			priceValue(cfg, currencySign, row.total-row.previous),
+		})
+	}
+
+	// Clear rows left over from a previous run with more groups.
+	for len(data) < len(previous.Values)+1 {
+		data = append(data, []interface{}{"", "", "", "", ""})
+	}
+
+	r.logger.Debug("built summary of %d group(s) by %s", len(sorted), cfg.SummaryGroupBy)
+
+	var formats []tables.NumberFormat
+	if len(sorted) > 0 {
+		end := len(sorted) + 1
+		formats = []tables.NumberFormat{
+			priceFormat(cfg.SummarySheet, "C2", fmt.Sprintf("C%d", end), currencySign),
+			percentFormat(cfg.SummarySheet, "D2", fmt.Sprintf("D%d", end)),
+			priceFormat(cfg.SummarySheet, "E2", fmt.Sprintf("E%d", end), currencySign),
+		}
+	}
+
+	return valueRange(tables.Cell(cfg.SummarySheet, "A1"), fmt.Sprintf("E%d", len(data)), data), formats
+}
+
+// groupItem returns the amount of the item per group of the dimension,
+// only the location may split an item into several groups
+func (r *runner) groupItem(dimension string, descs descriptions, item inventoryItem) map[string]int {
+	if dimension != "location" {
+		group, ok := filter.Tag(descs.get(item.MarketHashName), dimension)
+		if !ok {
+			group = summaryUnknown
+		}
+		return map[string]int{group: item.Amount}
+	}
+
+	groups := make(map[string]int)
+	remaining := item.Amount
+	for _, custom := range r.customItems {
+		if custom.MarketHashName != item.MarketHashName {
+			continue
+		}
+		location := custom.Location
+		if location == "" {
+			location = summaryItemsFile
+		}
+		groups[location] += custom.Amount
+		remaining -= custom.Amount
+	}
+	if remaining > 0 {
+		groups[summaryInventory] += remaining
+	}
+
+	return groups
+}
@@ -3,13 +3,16 @@ module github.com/devusSs/steamquery
 go 1.21.4
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/Masterminds/semver v1.5.0
 	github.com/fatih/color v1.16.0
 	github.com/rhysd/go-github-selfupdate v1.2.3
 	github.com/rs/zerolog v1.31.0
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/sys v0.14.0
 	google.golang.org/api v0.151.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -33,7 +36,6 @@ require (
 	golang.org/x/crypto v0.14.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/oauth2 v0.13.0 // indirect
-	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20231030173426-d783a09b4405 // indirect
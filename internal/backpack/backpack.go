@@ -3,6 +3,7 @@ package backpack
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -13,8 +14,63 @@ var (
 	ZeroPriceError = fmt.Errorf("item has no price")
 )
 
+// Limiter is asked for permission before a Client requests a price
+type Limiter interface {
+	Take(n int) error
+}
+
+// Option is a function that modifies the client
+type Option func(*Client)
+
+// WithLimiter makes the client take from l before every price request
+func WithLimiter(l Limiter) Option {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// WithHTTPClient sets the http client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client requests item prices from csgobackpack
+type Client struct {
+	httpClient *http.Client
+	limiter    Limiter
+}
+
+// NewClient creates a new client with the given options,
+// without a limiter requests are not rate limited
+func NewClient(options ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+var defaultClient = NewClient()
+
+// IsAvailable checks whether csgobackpack is reachable using the default client
 func IsAvailable() bool {
-	resp, err := http.Get(checkURL)
+	return defaultClient.IsAvailable()
+}
+
+// GetItemPrice returns the median price of an item using the default client
+func GetItemPrice(marketHashName string, options ...*RequestOptions) (float64, error) {
+	return defaultClient.GetItemPrice(marketHashName, options...)
+}
+
+// IsAvailable checks whether csgobackpack is reachable, this does not count towards the rate limit
+func (c *Client) IsAvailable() bool {
+	resp, err := c.httpClient.Get(checkURL)
 	if err != nil {
 		return false
 	}
@@ -37,7 +93,8 @@ func (r *RequestOptions) checkDefaults() {
 	}
 }
 
-func GetItemPrice(marketHashName string, options ...*RequestOptions) (float64, error) {
+// GetItemPrice returns the median price of an item
+func (c *Client) GetItemPrice(marketHashName string, options ...*RequestOptions) (float64, error) {
 	opt := &RequestOptions{}
 	if len(options) > 0 {
 		opt = options[0]
@@ -62,7 +119,13 @@ func GetItemPrice(marketHashName string, options ...*RequestOptions) (float64, e
 	}
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	if c.limiter != nil {
+		if err := c.limiter.Take(1); err != nil {
+			return 0, fmt.Errorf("taking from rate limit: %w", err)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("doing request: %w", err)
 	}
@@ -92,9 +155,52 @@ func GetItemPrice(marketHashName string, options ...*RequestOptions) (float64, e
 	return price, nil
 }
 
+// GetItemNames returns the market hash names of all items known to csgobackpack
+func (c *Client) GetItemNames() ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, itemsListURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	if c.limiter != nil {
+		if err := c.limiter.Take(1); err != nil {
+			return nil, fmt.Errorf("taking from rate limit: %w", err)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("doing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var res itemsListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	if string(res.Success) != "true" || len(res.ItemsList) == 0 {
+		return nil, fmt.Errorf("received empty items list")
+	}
+
+	names := make([]string, 0, len(res.ItemsList))
+	for name := range res.ItemsList {
+		// Some names are HTML escaped, e.g. apostrophes as &#39;.
+		names = append(names, html.UnescapeString(name))
+	}
+
+	return names, nil
+}
+
 const (
 	checkURL     string = "https://csgobackpack.net/"
 	itemPriceURL string = "https://csgobackpack.net/api/GetItemPrice/"
+	itemsListURL string = "https://csgobackpack.net/api/GetItemsList/v2/?no_details=true"
 )
 
 const (
@@ -115,3 +221,8 @@ type itemPriceResponse struct {
 	Icon              string          `json:"icon"`
 	Currency          string          `json:"currency"`
 }
+
+type itemsListResponse struct {
+	Success   json.RawMessage            `json:"success"`
+	ItemsList map[string]json.RawMessage `json:"items_list"`
+}
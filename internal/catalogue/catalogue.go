@@ -0,0 +1,201 @@
+// Provides a catalogue of known market hash names, cached on disk,
+// to catch typos in item names before they are priced
+package catalogue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultFileName is the name of the cache file inside the logs directory
+const DefaultFileName = ".catalogue.json"
+
+// DefaultMaxAge is how long the cached names are used before fetching them again
+const DefaultMaxAge = 24 * time.Hour
+
+// maxSuggestions is the maximum amount of suggestions for an unknown name
+const maxSuggestions = 3
+
+// Catalogue holds all known market hash names
+type Catalogue struct {
+	names map[string]bool
+	// known names by their lower case version
+	lower map[string]string
+}
+
+// New creates a catalogue of the given names
+func New(names []string) *Catalogue {
+	c := &Catalogue{
+		names: make(map[string]bool, len(names)),
+		lower: make(map[string]string, len(names)),
+	}
+	for _, name := range names {
+		c.names[name] = true
+		c.lower[strings.ToLower(name)] = name
+	}
+	return c
+}
+
+// Load returns the names cached at path if they are younger than maxAge, else calls fetch
+// and caches its result. If fetch fails, outdated cached names are used if there are any.
+//
+// The returned bool is true if the names had to be fetched.
+func Load(path string, maxAge time.Duration, fetch func() ([]string, error)) (*Catalogue, bool, error) {
+	cached, err := readCache(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if cached != nil && time.Since(cached.FetchedAt) < maxAge {
+		return New(cached.Names), false, nil
+	}
+
+	names, err := fetch()
+	if err != nil {
+		if cached != nil {
+			return New(cached.Names), true, nil
+		}
+		return nil, true, fmt.Errorf("fetching known item names: %w", err)
+	}
+
+	if err := writeCache(path, names); err != nil {
+		return nil, true, err
+	}
+
+	return New(names), true, nil
+}
+
+// Fresh returns true if the names cached at path are younger than maxAge, Load then does not fetch
+func Fresh(path string, maxAge time.Duration) bool {
+	cached, err := readCache(path)
+	return err == nil && cached != nil && time.Since(cached.FetchedAt) < maxAge
+}
+
+// Len returns the amount of known names
+func (c *Catalogue) Len() int {
+	return len(c.names)
+}
+
+// Lookup returns true if the name is known, else up to three similar known names
+func (c *Catalogue) Lookup(name string) (bool, []string) {
+	if c.names[name] {
+		return true, nil
+	}
+
+	lower := strings.ToLower(name)
+	if known, ok := c.lower[lower]; ok {
+		return false, []string{known}
+	}
+
+	// Allow roughly one typo per four characters, distance compares runes.
+	length := utf8.RuneCountInString(lower)
+	maxDistance := length/4 + 1
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	var candidates []candidate
+	for knownLower, known := range c.lower {
+		if abs(utf8.RuneCountInString(knownLower)-length) > maxDistance {
+			continue
+		}
+		if d := distance(lower, knownLower); d <= maxDistance {
+			candidates = append(candidates, candidate{name: known, distance: d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	suggestions := make([]string, 0, maxSuggestions)
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+
+	return false, suggestions
+}
+
+// distance returns the Levenshtein distance of a and b
+func distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+type cacheFile struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Names     []string  `json:"names"`
+}
+
+// readCache returns nil if there is no usable cache file
+func readCache(path string) (*cacheFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading item names cache: %w", err)
+	}
+
+	var cached cacheFile
+	if err := json.Unmarshal(content, &cached); err != nil || len(cached.Names) == 0 {
+		// A broken cache is simply fetched again.
+		return nil, nil
+	}
+
+	return &cached, nil
+}
+
+func writeCache(path string, names []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating item names cache directory: %w", err)
+	}
+
+	content, err := json.Marshal(cacheFile{FetchedAt: time.Now(), Names: names})
+	if err != nil {
+		return fmt.Errorf("marshalling item names cache: %w", err)
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("writing item names cache: %w", err)
+	}
+
+	return nil
+}
@@ -10,21 +10,54 @@ import (
 )
 
 type Config struct {
-	SteamUserID64     uint64 `json:"steam_user_id_64"    required:"true"  print:"true"`
-	SteamAPIKey       string `json:"steam_api_key"       required:"true"  print:"false"`
-	MedianPriceDays   uint   `json:"median_price_days"   required:"false" print:"true"  default:"7"`
-	Currency          string `json:"currency"            required:"false" print:"true"  default:"EUR"`
-	DecimalSeparator  string `json:"decimal_separator"   required:"false" print:"true"  default:","`
-	SpreadSheetID     string `json:"spreadsheet_id"      required:"true"  print:"false"`
-	LastUpdatedCell   string `json:"last_updated_cell"   required:"false" print:"true"  default:"G2"`
-	ErrorCell         string `json:"error_cell"          required:"false" print:"true"  default:"M2"`
-	TotalValueCell    string `json:"total_value_cell"    required:"false" print:"true"  default:"M4"`
-	DifferenceCell    string `json:"difference_cell"     required:"false" print:"true"  default:"M5"`
-	StartingRow       uint   `json:"starting_row"        required:"false" print:"true"  default:"9"`
-	ItemColumn        string `json:"item_column"         required:"false" print:"true"  default:"B"`
-	AmountColumn      string `json:"amount_column"       required:"false" print:"true"  default:"F"`
-	SinglePriceColumn string `json:"single_price_column" required:"false" print:"true"  default:"H"`
-	TotalPriceColumn  string `json:"total_price_column"  required:"false" print:"true"  default:"J"`
+	SteamUserID64      uint64 `json:"steam_user_id_64"     required:"true"  print:"true"`
+	SteamAPIKey        string `json:"steam_api_key"        required:"true"  print:"false"`
+	MedianPriceDays    uint   `json:"median_price_days"    required:"false" print:"true"  default:"7"`
+	Currency           string `json:"currency"             required:"false" print:"true"  default:"EUR"`
+	DecimalSeparator   string `json:"decimal_separator"    required:"false" print:"true"  default:","`
+	SpreadSheetID      string `json:"spreadsheet_id"       required:"true"  print:"false"`
+	LastUpdatedCell    string `json:"last_updated_cell"    required:"false" print:"true"  default:"G2"`
+	ErrorCell          string `json:"error_cell"           required:"false" print:"true"  default:"M2"`
+	TotalValueCell     string `json:"total_value_cell"     required:"false" print:"true"  default:"M4"`
+	DifferenceCell     string `json:"difference_cell"      required:"false" print:"true"  default:"M5"`
+	StartingRow        uint   `json:"starting_row"         required:"false" print:"true"  default:"9"`
+	ItemColumn         string `json:"item_column"          required:"false" print:"true"  default:"B"`
+	AmountColumn       string `json:"amount_column"        required:"false" print:"true"  default:"F"`
+	SinglePriceColumn  string `json:"single_price_column"  required:"false" print:"true"  default:"H"`
+	TotalPriceColumn   string `json:"total_price_column"   required:"false" print:"true"  default:"J"`
+	OverrideColumn     string `json:"override_column"      required:"false" print:"true"  default:"L"`
+	CostBasisColumn    string `json:"cost_basis_column"    required:"false" print:"true"`
+	ProfitColumn       string `json:"profit_column"        required:"false" print:"true"`
+	PurchaseDateColumn string `json:"purchase_date_column" required:"false" print:"true"`
+	NotesColumn        string `json:"notes_column"         required:"false" print:"true"`
+	GoogleCredentials  string `json:"google_credentials"   required:"false" print:"false"`
+	SummaryGroupBy     string `json:"summary_group_by"     required:"false" print:"true"`
+	SummarySheet       string `json:"summary_sheet"        required:"false" print:"true"  default:"Summary"`
+	NumericPrices      bool   `json:"numeric_prices"       required:"false" print:"true"  default:"false"`
+	HistorySheet       string `json:"history_sheet"        required:"false" print:"true"`
+	HistoryRetention   uint   `json:"history_retention"    required:"false" print:"true"`
+	PriceHistorySheet  string `json:"price_history_sheet"  required:"false" print:"true"`
+	PriceHistoryFormat string `json:"price_history_format" required:"false" print:"true"  default:"long"`
+}
+
+// SummaryDimensions are the supported values of summary_group_by
+var SummaryDimensions = []string{"type", "rarity", "collection", "exterior", "weapon", "location"}
+
+// PriceHistoryFormats are the supported values of price_history_format, long writes a row per item
+// and run, wide a column per run
+var PriceHistoryFormats = []string{"long", "wide"}
+
+// Values returns the string representation of all fields by json key,
+// including the ones with print:"false"
+func (c *Config) Values() map[string]string {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	m := make(map[string]string, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		m[t.Field(i).Tag.Get("json")] = fmt.Sprint(v.Field(i).Interface())
+	}
+	return m
 }
 
 func (c *Config) String() string {
@@ -49,6 +82,12 @@ func (c *Config) String() string {
 	return string(content)
 }
 
+// Validate fills defaults and checks that all required fields are set
+// and all values are valid, reporting every problem at once
+func (c *Config) Validate() error {
+	return c.validate()
+}
+
 func (c *Config) validate() error {
 	v := reflect.ValueOf(c).Elem()
 
@@ -58,44 +97,20 @@ func (c *Config) validate() error {
 		field := v.Field(i)
 		fieldType := v.Type().Field(i)
 
-		requiredTag := fieldType.Tag.Get("required")
-		defaultTag := fieldType.Tag.Get("default")
-
-		if requiredTag == "true" && isZeroValue(field) {
+		if fieldType.Tag.Get("required") == "true" && isZeroValue(field) {
 			validationErrors = append(
 				validationErrors,
 				fmt.Sprintf("field \"%s\" is required but empty", fieldType.Tag.Get("json")),
 			)
 		}
+	}
 
-		if defaultTag != "" && isZeroValue(field) {
-			switch field.Kind() {
-			case reflect.String:
-				field.SetString(defaultTag)
-			case reflect.Int:
-				defaultValue, err := strconv.ParseInt(defaultTag, 10, 64)
-				if err != nil {
-					return fmt.Errorf(
-						"error parsing default value for field \"%s\": %v",
-						fieldType.Tag.Get("json"),
-						err,
-					)
-				}
-				field.SetInt(defaultValue)
-			case reflect.Uint:
-				defaultValue, err := strconv.ParseUint(defaultTag, 10, 64)
-				if err != nil {
-					return fmt.Errorf(
-						"error parsing default value for field \"%s\": %v",
-						fieldType.Tag.Get("json"),
-						err,
-					)
-				}
-				field.SetUint(defaultValue)
-			}
-		}
+	if err := c.setDefaults(); err != nil {
+		return err
 	}
 
+	validationErrors = append(validationErrors, c.validateValues()...)
+
 	if len(validationErrors) > 0 {
 		return fmt.Errorf("validation failed: %s", strings.Join(validationErrors, "; "))
 	}
@@ -103,16 +118,186 @@ func (c *Config) validate() error {
 	return nil
 }
 
-func Load(path string) (*Config, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("opening config file: %v", err)
+// setDefaults fills all empty fields which have a default tag
+func (c *Config) setDefaults() error {
+	v := reflect.ValueOf(c).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		fieldType := v.Type().Field(i)
+
+		defaultTag := fieldType.Tag.Get("default")
+		if defaultTag != "" && isZeroValue(field) {
+			if err := setField(field, defaultTag); err != nil {
+				return fmt.Errorf(
+					"error parsing default value for field \"%s\": %v",
+					fieldType.Tag.Get("json"),
+					err,
+				)
+			}
+		}
 	}
-	defer f.Close()
 
+	return nil
+}
+
+// Default returns a config with only the default values set
+func Default() *Config {
 	var cfg Config
-	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("decoding config file: %v", err)
+	if err := cfg.setDefaults(); err != nil {
+		// Default tags are constant, this is a programming error.
+		panic(err)
+	}
+	return &cfg
+}
+
+// Field describes a config field by its struct tags
+type Field struct {
+	Key      string
+	Required bool
+	Print    bool
+	Default  string
+	Kind     reflect.Kind
+}
+
+// Fields returns all config fields in declaration order
+func Fields() []Field {
+	t := reflect.TypeOf(Config{})
+
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fields = append(fields, Field{
+			Key:      f.Tag.Get("json"),
+			Required: f.Tag.Get("required") == "true",
+			Print:    f.Tag.Get("print") == "true",
+			Default:  f.Tag.Get("default"),
+			Kind:     f.Type.Kind(),
+		})
+	}
+	return fields
+}
+
+// Set sets the field with the given json key from its string representation
+func (c *Config) Set(key string, value string) error {
+	field, ok := fieldByJSONKey(c, key)
+	if !ok {
+		return fmt.Errorf("unknown field \"%s\"", key)
+	}
+	return setField(field, value)
+}
+
+// EnvPrefix is prepended to the upper case json key of a field
+// to get the environment variable overriding it
+const EnvPrefix = "STEAMQUERY_"
+
+// EnvName returns the environment variable overriding the field with the given json key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// Option is a function that modifies how the config is loaded
+type Option func(*loader)
+
+// WithOverrides overrides fields by "json_key=value" pairs, e.g. from --set flags
+func WithOverrides(overrides []string) Option {
+	return func(l *loader) {
+		l.overrides = append(l.overrides, overrides...)
+	}
+}
+
+// WithSources records the source of every field value by json key,
+// fields which are neither set nor defaulted are left out
+func WithSources(sources map[string]Source) Option {
+	return func(l *loader) {
+		l.sources = sources
+	}
+}
+
+// Source tells where the value of a field came from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceProfile Source = "profile"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+type loader struct {
+	overrides []string
+	profile   string
+	sources   map[string]Source
+}
+
+// Load loads the config with the following precedence (highest first):
+//
+//  1. overrides (WithOverrides)
+//  2. environment variables (see EnvName)
+//  3. the selected profile of the config file (WithProfile)
+//  4. the common fields of the config file at path (JSON, YAML or TOML by extension),
+//     skipped if path is empty
+//  5. default tags
+//
+// Secret references of fields with print:"false" (e.g. "file:/run/secrets/steam_key",
+// "env:VAR" or "cmd:pass show steam") are resolved after merging.
+// Required fields are checked and defaults filled afterwards.
+func Load(path string, options ...Option) (*Config, error) {
+	l := &loader{sources: make(map[string]Source)}
+	for _, option := range options {
+		option(l)
+	}
+
+	var cfg Config
+
+	if path != "" {
+		raw, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		merged, err := selectProfile(raw, l.profile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cfg.decodeMap(merged); err != nil {
+			return nil, err
+		}
+
+		for key := range merged {
+			l.sources[key] = SourceFile
+		}
+		if l.profile != "" {
+			profiles, _ := profilesOf(raw)
+			for key := range profiles[l.profile] {
+				l.sources[key] = SourceProfile
+			}
+		}
+	} else if l.profile != "" {
+		return nil, fmt.Errorf("profile \"%s\" requires a config file", l.profile)
+	}
+
+	if err := cfg.applyEnv(l.sources); err != nil {
+		return nil, fmt.Errorf("applying environment: %v", err)
+	}
+
+	if err := cfg.applyOverrides(l.overrides, l.sources); err != nil {
+		return nil, fmt.Errorf("applying overrides: %v", err)
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	// Empty fields are filled by validate if they have a default.
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fieldType := v.Type().Field(i)
+		if fieldType.Tag.Get("default") != "" && isZeroValue(v.Field(i)) {
+			l.sources[fieldType.Tag.Get("json")] = SourceDefault
+		}
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -122,6 +307,78 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+func (c *Config) applyEnv(sources map[string]Source) error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := v.Type().Field(i).Tag.Get("json")
+
+		value, ok := os.LookupEnv(EnvName(key))
+		if !ok {
+			continue
+		}
+
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("%s: %v", EnvName(key), err)
+		}
+		sources[key] = SourceEnv
+	}
+	return nil
+}
+
+func (c *Config) applyOverrides(overrides []string, sources map[string]Source) error {
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return fmt.Errorf("invalid override \"%s\", expected key=value", override)
+		}
+
+		key = strings.TrimSpace(key)
+		if err := c.Set(key, value); err != nil {
+			return err
+		}
+		sources[key] = SourceFlag
+	}
+	return nil
+}
+
+func fieldByJSONKey(c *Config, key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("json") == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Kind())
+	}
+	return nil
+}
+
 func isZeroValue(field reflect.Value) bool {
 	return reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) ||
 		(field.Kind() == reflect.Ptr && field.IsNil())
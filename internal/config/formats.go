@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile decodes a YAML or TOML config file (detected by extension) or
+// a JSON config file (any other extension) into a generic map keyed by the json keys of Config
+func readFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening config file: %v", err)
+	}
+
+	raw := make(map[string]interface{})
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("decoding YAML config file: %v", err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("decoding TOML config file: %v", err)
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(content))
+		// Keeps large IDs like steam_user_id_64 exact.
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("decoding JSON config file: %v", err)
+		}
+	}
+
+	return raw, nil
+}
+
+// decodeMap sets the fields of c from a map read by readFile
+func (c *Config) decodeMap(raw map[string]interface{}) error {
+	content, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("encoding config values: %v", err)
+	}
+
+	if err := json.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("decoding config values: %v", err)
+	}
+
+	return nil
+}
+
+// Save writes the config to path, as YAML or TOML depending on
+// the extension and as indented JSON otherwise
+func (c *Config) Save(path string) error {
+	var content []byte
+	var err error
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		content, err = c.marshalMap(yaml.Marshal)
+	case ".toml":
+		content, err = c.marshalMap(marshalTOML)
+	default:
+		content, err = json.MarshalIndent(c, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("encoding config: %v", err)
+	}
+
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("writing config file: %v", err)
+	}
+
+	return nil
+}
+
+// marshalMap encodes the config as a generic map keyed by json keys
+func (c *Config) marshalMap(marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	raw := make(map[string]interface{})
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	// json.Number is a string to YAML and TOML encoders.
+	for key, value := range raw {
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				raw[key] = i
+			} else {
+				raw[key] = n.String()
+			}
+		}
+	}
+
+	return marshal(raw)
+}
+
+func marshalTOML(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
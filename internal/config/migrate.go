@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Migrate reads an older config file and carries over the fields sharing a json key with Config,
+// it also returns the keys of all other fields so they can be moved by hand
+func Migrate(path string) (*Config, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening old config file: %v", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("decoding old config file: %v", err)
+	}
+
+	var cfg Config
+	var unmapped []string
+
+	for key, value := range raw {
+		field, ok := fieldByJSONKey(&cfg, key)
+		if !ok {
+			unmapped = append(unmapped, key)
+			continue
+		}
+
+		switch value.(type) {
+		case string, json.Number, bool:
+		default:
+			unmapped = append(unmapped, key)
+			continue
+		}
+
+		if err := setField(field, fmt.Sprint(value)); err != nil {
+			return nil, nil, fmt.Errorf("field \"%s\": %v", key, err)
+		}
+	}
+
+	sort.Strings(unmapped)
+
+	if err := cfg.validate(); err != nil {
+		return nil, unmapped, fmt.Errorf("config validation: %v", err)
+	}
+
+	return &cfg, unmapped, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// profilesKey is the config file key holding the named profiles,
+// all other keys are common to every profile
+const profilesKey = "profiles"
+
+// WithProfile loads the named profile, its fields override the common fields of the config file
+func WithProfile(name string) Option {
+	return func(l *loader) {
+		l.profile = name
+	}
+}
+
+// Profiles returns the sorted names of all profiles in the config file
+func Profiles(path string) ([]string, error) {
+	raw, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := profilesOf(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// selectProfile returns the common fields of raw merged with the fields of the named profile,
+// an empty name only returns the common fields
+func selectProfile(raw map[string]interface{}, name string) (map[string]interface{}, error) {
+	profiles, err := profilesOf(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		if key != profilesKey {
+			merged[key] = value
+		}
+	}
+
+	if name == "" {
+		return merged, nil
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile \"%s\" not found in config file", name)
+	}
+
+	for key, value := range profile {
+		if key == profilesKey {
+			return nil, fmt.Errorf("profile \"%s\": profiles cannot be nested", name)
+		}
+		merged[key] = value
+	}
+
+	return merged, nil
+}
+
+func profilesOf(raw map[string]interface{}) (map[string]map[string]interface{}, error) {
+	value, ok := raw[profilesKey]
+	if !ok {
+		return nil, nil
+	}
+
+	section, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field \"%s\" must be a map of profile names to fields", profilesKey)
+	}
+
+	profiles := make(map[string]map[string]interface{}, len(section))
+	for name, value := range section {
+		profile, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile \"%s\" must be a map of fields", name)
+		}
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
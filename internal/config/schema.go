@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/devusSs/steamquery/pkg/steam"
+)
+
+const schemaDraft = "https://json-schema.org/draft-07/schema#"
+
+// Schema returns a JSON Schema of the config file generated from the struct tags,
+// e.g. for autocompletion in editors
+func Schema() ([]byte, error) {
+	var cfg Config
+	patterns := make(map[string]string)
+	for _, column := range cfg.columns() {
+		if column.optional {
+			patterns[column.key] = "^([A-Za-z]{1,3})?$"
+			continue
+		}
+		patterns[column.key] = "^[A-Za-z]{1,3}$"
+	}
+	for _, cell := range cfg.cells() {
+		patterns[cell.key] = "^[A-Za-z]{1,3}[1-9][0-9]*$"
+	}
+
+	properties := make(map[string]interface{})
+	var required []string
+
+	for _, field := range Fields() {
+		property := map[string]interface{}{}
+
+		switch field.Kind {
+		case reflect.String:
+			property["type"] = "string"
+			if field.Default != "" {
+				property["default"] = field.Default
+			}
+		case reflect.Int, reflect.Int64:
+			property["type"] = "integer"
+			if d, err := strconv.ParseInt(field.Default, 10, 64); err == nil {
+				property["default"] = d
+			}
+		case reflect.Uint, reflect.Uint64:
+			property["type"] = "integer"
+			property["minimum"] = 0
+			if d, err := strconv.ParseUint(field.Default, 10, 64); err == nil {
+				property["default"] = d
+			}
+		case reflect.Bool:
+			property["type"] = "boolean"
+			if d, err := strconv.ParseBool(field.Default); err == nil {
+				property["default"] = d
+			}
+		}
+
+		if pattern, ok := patterns[field.Key]; ok {
+			property["pattern"] = pattern
+		}
+		if field.Key == "currency" {
+			property["enum"] = steam.SupportedCurrencies()
+		}
+		if field.Key == "summary_group_by" {
+			property["enum"] = append([]string{""}, SummaryDimensions...)
+		}
+		if field.Key == "price_history_format" {
+			property["enum"] = PriceHistoryFormats
+		}
+		if !field.Print {
+			property["description"] = "Secret, may be a reference like file:<path>, env:<variable> or cmd:<command>"
+		}
+
+		properties[field.Key] = property
+		if field.Required {
+			required = append(required, field.Key)
+		}
+	}
+
+	// Profiles override any field, so nothing is required there.
+	profileProperties := make(map[string]interface{}, len(properties))
+	for key, property := range properties {
+		profileProperties[key] = property
+	}
+
+	properties[profilesKey] = map[string]interface{}{
+		"type":        "object",
+		"description": "Named profiles, selected with --profile, overriding the common fields",
+		"additionalProperties": map[string]interface{}{
+			"type":                 "object",
+			"properties":           profileProperties,
+			"additionalProperties": false,
+		},
+	}
+
+	// Allows referencing the schema from within a config file.
+	properties["$schema"] = map[string]interface{}{"type": "string"}
+
+	schema := map[string]interface{}{
+		"$schema":              schemaDraft,
+		"title":                "steamquery config",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
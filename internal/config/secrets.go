@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// secretTimeout limits how long a "cmd:" secret command may run
+const secretTimeout = 30 * time.Second
+
+// resolveSecrets replaces secret references in all string fields with print:"false",
+// supported references are "file:<path>", "env:<variable>" and "cmd:<command>"
+func (c *Config) resolveSecrets() error {
+	v := reflect.ValueOf(c).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		fieldType := v.Type().Field(i)
+
+		if fieldType.Tag.Get("print") != "false" || field.Kind() != reflect.String {
+			continue
+		}
+
+		value, err := resolveSecret(field.String())
+		if err != nil {
+			// Never include the secret itself, only where it should come from.
+			return fmt.Errorf("resolving secret for field \"%s\": %v", fieldType.Tag.Get("json"), err)
+		}
+		field.SetString(value)
+	}
+
+	return nil
+}
+
+func resolveSecret(ref string) (string, error) {
+	kind, target, ok := strings.Cut(ref, ":")
+	if !ok {
+		return ref, nil
+	}
+
+	switch kind {
+	case "file":
+		content, err := os.ReadFile(target)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	case "env":
+		value, ok := os.LookupEnv(target)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", target)
+		}
+		return value, nil
+	case "cmd":
+		args := strings.Fields(target)
+		if len(args) == 0 {
+			return "", fmt.Errorf("empty command")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("running command %s: %v", args[0], err)
+		}
+		return strings.TrimSpace(string(output)), nil
+	default:
+		// Not a reference, e.g. a plain value containing a colon.
+		return ref, nil
+	}
+}
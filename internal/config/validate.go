@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/devusSs/steamquery/pkg/steam"
+)
+
+var (
+	cellPattern   = regexp.MustCompile(`^([A-Z]{1,3})([1-9][0-9]*)$`)
+	columnPattern = regexp.MustCompile(`^[A-Z]{1,3}$`)
+)
+
+type namedValue struct {
+	key   string
+	value *string
+	// optional values are empty if unused
+	optional bool
+}
+
+// normalize upper cases currency, cells and columns since
+// the Sheets API accepts both cases anyway
+func (c *Config) normalize() {
+	for _, value := range append(c.columns(), c.cells()...) {
+		*value.value = strings.ToUpper(strings.TrimSpace(*value.value))
+	}
+	c.Currency = strings.ToUpper(strings.TrimSpace(c.Currency))
+	c.SummaryGroupBy = strings.ToLower(strings.TrimSpace(c.SummaryGroupBy))
+	c.PriceHistoryFormat = strings.ToLower(strings.TrimSpace(c.PriceHistoryFormat))
+}
+
+func (c *Config) columns() []namedValue {
+	return []namedValue{
+		{"item_column", &c.ItemColumn, false},
+		{"amount_column", &c.AmountColumn, false},
+		{"single_price_column", &c.SinglePriceColumn, false},
+		{"total_price_column", &c.TotalPriceColumn, false},
+		{"override_column", &c.OverrideColumn, false},
+		{"cost_basis_column", &c.CostBasisColumn, true},
+		{"profit_column", &c.ProfitColumn, true},
+		{"purchase_date_column", &c.PurchaseDateColumn, true},
+		{"notes_column", &c.NotesColumn, true},
+	}
+}
+
+func (c *Config) cells() []namedValue {
+	return []namedValue{
+		{"last_updated_cell", &c.LastUpdatedCell, false},
+		{"error_cell", &c.ErrorCell, false},
+		{"total_value_cell", &c.TotalValueCell, false},
+		{"difference_cell", &c.DifferenceCell, false},
+	}
+}
+
+// validateValues checks the semantics of all (already defaulted) values
+// and returns every problem found, prefixed by the json key of the field
+func (c *Config) validateValues() []string {
+	c.normalize()
+
+	var problems []string
+	addProblem := func(key string, format string, args ...interface{}) {
+		problems = append(
+			problems,
+			fmt.Sprintf("field \"%s\": %s", key, fmt.Sprintf(format, args...)),
+		)
+	}
+
+	if _, err := steam.GetCurrencySignByCode(c.Currency); err != nil {
+		addProblem("currency", "%s is not a supported currency", c.Currency)
+	}
+
+	if utf8.RuneCountInString(c.DecimalSeparator) != 1 {
+		addProblem("decimal_separator", "\"%s\" must be a single character", c.DecimalSeparator)
+	} else if strings.ContainsAny(c.DecimalSeparator, "0123456789") {
+		addProblem("decimal_separator", "\"%s\" must not be a digit", c.DecimalSeparator)
+	}
+
+	if c.SummaryGroupBy != "" && !slices.Contains(SummaryDimensions, c.SummaryGroupBy) {
+		addProblem("summary_group_by", "\"%s\" must be one of %s", c.SummaryGroupBy, strings.Join(SummaryDimensions, ", "))
+	}
+
+	if !slices.Contains(PriceHistoryFormats, c.PriceHistoryFormat) {
+		addProblem("price_history_format", "\"%s\" must be one of %s", c.PriceHistoryFormat, strings.Join(PriceHistoryFormats, ", "))
+	}
+
+	// Every run rewrites or appends to these sheets, so they must not share a tab.
+	sheets := []namedValue{
+		{"history_sheet", &c.HistorySheet, true},
+		{"price_history_sheet", &c.PriceHistorySheet, true},
+	}
+	if c.SummaryGroupBy != "" {
+		sheets = append([]namedValue{{"summary_sheet", &c.SummarySheet, true}}, sheets...)
+	}
+	usedSheets := make(map[string]string)
+	for _, sheet := range sheets {
+		value := strings.TrimSpace(*sheet.value)
+		if value == "" {
+			continue
+		}
+		// Sheet names are unique regardless of case.
+		if other, ok := usedSheets[strings.ToLower(value)]; ok {
+			addProblem(sheet.key, "sheet %s is already used by \"%s\"", value, other)
+			continue
+		}
+		usedSheets[strings.ToLower(value)] = sheet.key
+	}
+
+	usedColumns := make(map[string]string)
+	for _, column := range c.columns() {
+		value := *column.value
+		if value == "" && column.optional {
+			continue
+		}
+		if !columnPattern.MatchString(value) {
+			addProblem(column.key, "\"%s\" is not a column letter (e.g. \"B\")", value)
+			continue
+		}
+		if other, ok := usedColumns[value]; ok {
+			addProblem(column.key, "column %s is already used by \"%s\"", value, other)
+			continue
+		}
+		usedColumns[value] = column.key
+	}
+
+	usedCells := make(map[string]string)
+	for _, cell := range c.cells() {
+		value := *cell.value
+		match := cellPattern.FindStringSubmatch(value)
+		if match == nil {
+			addProblem(cell.key, "\"%s\" is not a cell in A1 notation (e.g. \"M4\")", value)
+			continue
+		}
+		if other, ok := usedCells[value]; ok {
+			addProblem(cell.key, "cell %s is already used by \"%s\"", value, other)
+			continue
+		}
+		usedCells[value] = cell.key
+
+		row, _ := strconv.ParseUint(match[2], 10, 64)
+		if column, ok := usedColumns[match[1]]; ok && uint(row) >= c.StartingRow {
+			addProblem(
+				cell.key,
+				"cell %s collides with the item rows of \"%s\" (starting at row %d)",
+				value,
+				column,
+				c.StartingRow,
+			)
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,300 @@
+// Loads the custom items file, items which are not in the inventory
+// (e.g. in storage units) or need extra information like their cost basis
+package customitems
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Item is a single entry of the items file, all fields but MarketHashName and Amount are optional
+type Item struct {
+	MarketHashName string `json:"market_hash_name"`
+	Amount         int    `json:"amount"`
+	// per unit
+	PurchasePrice float64 `json:"purchase_price,omitempty"`
+	// YYYY-MM-DD
+	PurchaseDate string `json:"purchase_date,omitempty"`
+	// e.g. "Storage Unit 3"
+	Location string `json:"location,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+	// Price fixes the price of the item instead of querying the price source
+	Price *float64 `json:"price,omitempty"`
+}
+
+// Load reads a JSON or, by its .csv extension, a CSV items file, an empty path returns no items
+//
+// CSV files need a header row naming the columns like the JSON fields.
+func Load(filePath string) ([]Item, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening items file: %w", err)
+	}
+	defer f.Close()
+
+	var items []Item
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		items, err = decodeCSV(f)
+	} else {
+		items, err = decodeJSON(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding items file: %w", err)
+	}
+
+	if err := validate(items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// CheckNames makes sure every item name is known to lookup, which returns
+// whether a name is known and else similar known names
+func CheckNames(items []Item, lookup func(name string) (bool, []string)) error {
+	var problems []string
+	checked := make(map[string]bool, len(items))
+	for _, item := range items {
+		if checked[item.MarketHashName] {
+			continue
+		}
+		checked[item.MarketHashName] = true
+
+		known, suggestions := lookup(item.MarketHashName)
+		if known {
+			continue
+		}
+
+		problem := fmt.Sprintf("unknown item \"%s\"", item.MarketHashName)
+		if len(suggestions) > 0 {
+			problem += fmt.Sprintf(", did you mean '%s'?", strings.Join(suggestions, "' or '"))
+		}
+		problems = append(problems, problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("checking items file names: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// AddAmounts adds the amounts of the items to the amount map by market hash name
+func AddAmounts(amountMap map[string]int, items []Item) map[string]int {
+	for _, item := range items {
+		amountMap[item.MarketHashName] += item.Amount
+	}
+	return amountMap
+}
+
+// Purchase sums up the purchase information of all entries of an item
+type Purchase struct {
+	// Amount is the amount of the entries with a purchase price, Cost what was paid for them
+	Amount int
+	Cost   float64
+	// FirstDate is the earliest purchase date (YYYY-MM-DD), empty if none is set
+	FirstDate string
+	Notes     []string
+}
+
+// Purchases returns the purchase information of the items by market hash name,
+// items without purchase price, date and notes are left out
+func Purchases(items []Item) map[string]Purchase {
+	purchases := make(map[string]Purchase)
+	for _, item := range items {
+		if item.PurchasePrice == 0 && item.PurchaseDate == "" && item.Notes == "" {
+			continue
+		}
+
+		p := purchases[item.MarketHashName]
+		if item.PurchasePrice > 0 {
+			p.Amount += item.Amount
+			p.Cost += item.PurchasePrice * float64(item.Amount)
+		}
+		// Dates are validated to be YYYY-MM-DD, so they sort as strings.
+		if item.PurchaseDate != "" && (p.FirstDate == "" || item.PurchaseDate < p.FirstDate) {
+			p.FirstDate = item.PurchaseDate
+		}
+		if item.Notes != "" {
+			p.Notes = append(p.Notes, item.Notes)
+		}
+		purchases[item.MarketHashName] = p
+	}
+	return purchases
+}
+
+type itemsFileStructure struct {
+	Items []Item `json:"items"`
+}
+
+func decodeJSON(r io.Reader) ([]Item, error) {
+	var content itemsFileStructure
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&content); err != nil {
+		return nil, err
+	}
+	return content.Items, nil
+}
+
+// csvColumns sets the field of an Item from a CSV cell by column name
+var csvColumns = map[string]func(item *Item, value string) error{
+	"market_hash_name": func(item *Item, value string) error {
+		item.MarketHashName = value
+		return nil
+	},
+	"amount": func(item *Item, value string) (err error) {
+		item.Amount, err = strconv.Atoi(value)
+		return err
+	},
+	"purchase_price": func(item *Item, value string) (err error) {
+		item.PurchasePrice, err = parseFloat(value)
+		return err
+	},
+	"purchase_date": func(item *Item, value string) error {
+		item.PurchaseDate = value
+		return nil
+	},
+	"location": func(item *Item, value string) error {
+		item.Location = value
+		return nil
+	},
+	"notes": func(item *Item, value string) error {
+		item.Notes = value
+		return nil
+	},
+	"price": func(item *Item, value string) error {
+		price, err := parseFloat(value)
+		item.Price = &price
+		return err
+	},
+}
+
+func decodeCSV(r io.Reader) ([]Item, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("missing header row")
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := csvColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown column \"%s\"", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate column \"%s\"", column)
+		}
+		seen[column] = true
+		header[i] = column
+	}
+	if !seen["market_hash_name"] || !seen["amount"] {
+		return nil, fmt.Errorf("header row needs the market_hash_name and amount columns")
+	}
+
+	var items []Item
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		var item Item
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if err := csvColumns[header[i]](&item, value); err != nil {
+				return nil, fmt.Errorf("line %d: column \"%s\": invalid value \"%s\"", line, header[i], value)
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// parseFloat also accepts a comma as decimal separator, as pasted from spreadsheets
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+}
+
+type itemKey struct {
+	marketHashName string
+	location       string
+}
+
+// validate returns all problems of the items at once, entries are
+// duplicates if they share market hash name and location
+func validate(items []Item) error {
+	var problems []string
+	addProblem := func(i int, item Item, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("item %d (%s): %s", i+1, item.MarketHashName, fmt.Sprintf(format, args...)))
+	}
+
+	seen := make(map[itemKey]int, len(items))
+	prices := make(map[string]float64, len(items))
+	for i, item := range items {
+		if item.MarketHashName == "" {
+			addProblem(i, item, "market_hash_name is empty")
+			continue
+		}
+		if item.Amount < 0 {
+			addProblem(i, item, "amount must not be negative")
+		}
+		if item.PurchasePrice < 0 {
+			addProblem(i, item, "purchase_price must not be negative")
+		}
+		if item.PurchaseDate != "" {
+			if _, err := time.Parse(time.DateOnly, item.PurchaseDate); err != nil {
+				addProblem(i, item, "purchase_date must be of format YYYY-MM-DD")
+			}
+		}
+		if item.Price != nil {
+			if *item.Price < 0 {
+				addProblem(i, item, "price must not be negative")
+			}
+			if price, ok := prices[item.MarketHashName]; ok && price != *item.Price {
+				addProblem(i, item, "price %.2f conflicts with price %.2f of another entry", *item.Price, price)
+			}
+			prices[item.MarketHashName] = *item.Price
+		}
+
+		key := itemKey{marketHashName: item.MarketHashName, location: item.Location}
+		if first, ok := seen[key]; ok {
+			addProblem(i, item, "duplicate entry of item %d (same name and location)", first+1)
+			continue
+		}
+		seen[key] = i
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("validating items file: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -22,3 +22,8 @@ func replaceDecimalSeparator(price float64, separator string) string {
 func addCurrencySign(price string, sign string) string {
 	return fmt.Sprintf("%s%s", price, sign)
 }
+
+// FormatPercentPrintable formats a percentage like 12,34%
+func FormatPercentPrintable(percent float64, separator string) string {
+	return strings.ReplaceAll(fmt.Sprintf("%.2f", percent), ".", separator) + "%"
+}
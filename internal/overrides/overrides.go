@@ -0,0 +1,122 @@
+package overrides
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Override fixes the price of an item instead of querying the price source
+type Override struct {
+	MarketHashName string  `json:"market_hash_name"`
+	Price          float64 `json:"price"`
+	Currency       string  `json:"currency"`
+	Expires        string  `json:"expires,omitempty"`
+
+	expiresAt time.Time
+}
+
+// Expired returns true if the override has an expiry date and it has passed
+func (o Override) Expired(now time.Time) bool {
+	if o.expiresAt.IsZero() {
+		return false
+	}
+	return !now.Before(o.expiresAt)
+}
+
+// Set holds all loaded overrides by market hash name
+type Set struct {
+	entries map[string]Override
+}
+
+// Load reads an overrides file, an empty path returns an empty set
+func Load(filePath string) (*Set, error) {
+	s := &Set{entries: make(map[string]Override)}
+	if filePath == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening overrides file: %w", err)
+	}
+	defer f.Close()
+
+	var content overridesFileStructure
+	if err := json.NewDecoder(f).Decode(&content); err != nil {
+		return nil, fmt.Errorf("error decoding overrides file: %w", err)
+	}
+
+	for i, o := range content.Overrides {
+		if o.MarketHashName == "" {
+			return nil, fmt.Errorf("override %d: market_hash_name is empty", i)
+		}
+		if o.Price < 0 {
+			return nil, fmt.Errorf("override for %s: price must not be negative", o.MarketHashName)
+		}
+		if _, ok := s.entries[o.MarketHashName]; ok {
+			return nil, fmt.Errorf("override for %s: duplicate entry", o.MarketHashName)
+		}
+		if o.Expires != "" {
+			o.expiresAt, err = time.ParseInLocation(time.DateOnly, o.Expires, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("override for %s: parsing expiry date: %w", o.MarketHashName, err)
+			}
+			// Overrides are valid through the whole day of expiry.
+			o.expiresAt = o.expiresAt.AddDate(0, 0, 1)
+		}
+		o.Currency = strings.ToUpper(o.Currency)
+		s.entries[o.MarketHashName] = o
+	}
+
+	return s, nil
+}
+
+// Add adds an override which did not come from the overrides file,
+// it fails if there already is one for the item
+func (s *Set) Add(o Override) error {
+	if _, ok := s.entries[o.MarketHashName]; ok {
+		return fmt.Errorf("override for %s: duplicate entry", o.MarketHashName)
+	}
+	o.Currency = strings.ToUpper(o.Currency)
+	s.entries[o.MarketHashName] = o
+	return nil
+}
+
+// CheckCurrency makes sure every override is priced in the given currency,
+// overrides without a currency are assumed to use it
+func (s *Set) CheckCurrency(currency string) error {
+	for name, o := range s.entries {
+		if o.Currency == "" {
+			o.Currency = currency
+			s.entries[name] = o
+			continue
+		}
+		if o.Currency != currency {
+			return fmt.Errorf(
+				"override for %s uses currency %s, but config uses %s",
+				name,
+				o.Currency,
+				currency,
+			)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the override for the given item if there is one
+func (s *Set) Lookup(marketHashName string) (Override, bool) {
+	o, ok := s.entries[marketHashName]
+	return o, ok
+}
+
+// Len returns the amount of loaded overrides
+func (s *Set) Len() int {
+	return len(s.entries)
+}
+
+type overridesFileStructure struct {
+	Overrides []Override `json:"overrides"`
+}
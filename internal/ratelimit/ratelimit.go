@@ -0,0 +1,380 @@
+// Provides a sliding window rate limiter with named buckets
+// and persistent state shared by all buckets in one file
+//
+// The state file is guarded by an advisory lock and replaced atomically,
+// so several processes may share it without losing requests
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFileName is the name of the state file inside the logs directory
+const DefaultFileName = ".ratelimit.json"
+
+// ErrLimitExceeded is returned when a bucket has no budget left
+var ErrLimitExceeded = errors.New("rate limit exceeded")
+
+// Bucket describes a named request budget of Limit requests per Window
+type Bucket struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// String returns a string representation of the Bucket
+func (b Bucket) String() string {
+	return fmt.Sprintf("%s: %d request(s) per %s", b.Name, b.Limit, b.Window)
+}
+
+// Limiter keeps a log of requests per bucket and only allows
+// a request if less than Limit requests happened in the last Window
+type Limiter struct {
+	mu       sync.Mutex
+	path     string
+	buckets  map[string]Bucket
+	requests map[string][]time.Time
+	// requests and resets since the last sync with the state file
+	pending map[string][]time.Time
+	resets  map[string]bool
+	// path of the backup of a corrupted state file, if any
+	recovered string
+}
+
+// New creates a new Limiter for the given buckets and loads
+// the previous state from path if it exists, creating its directory if needed
+func New(path string, buckets ...Bucket) (*Limiter, error) {
+	l := &Limiter{
+		path:     path,
+		buckets:  make(map[string]Bucket, len(buckets)),
+		requests: make(map[string][]time.Time, len(buckets)),
+		pending:  make(map[string][]time.Time, len(buckets)),
+		resets:   make(map[string]bool, len(buckets)),
+	}
+
+	for _, b := range buckets {
+		if b.Name == "" || b.Limit <= 0 || b.Window <= 0 {
+			return nil, fmt.Errorf("invalid bucket: %v", b)
+		}
+		l.buckets[b.Name] = b
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating rate limit state directory: %w", err)
+	}
+
+	if err := l.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Refresh merges requests recorded by other processes into the limiter
+func (l *Limiter) Refresh() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.withFileLock(func() error {
+		state, err := l.read()
+		if err != nil {
+			return err
+		}
+		l.merge(state)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("loading rate limit state: %w", err)
+	}
+
+	return nil
+}
+
+// Recovered returns the path the state file was moved to if it was corrupted
+// and had to be reset, empty if it never was
+func (l *Limiter) Recovered() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.recovered
+}
+
+// Allow returns true if n more requests fit into the bucket right now
+func (l *Limiter) Allow(name string, n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[name]
+	if !ok {
+		return false
+	}
+	return len(l.prune(b, time.Now()))+n <= b.Limit
+}
+
+// Take records n requests for the bucket if they fit, else
+// returns ErrLimitExceeded without recording anything
+func (l *Limiter) Take(name string, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[name]
+	if !ok {
+		return fmt.Errorf("unknown bucket: %s", name)
+	}
+
+	now := time.Now()
+	requests := l.prune(b, now)
+	if len(requests)+n > b.Limit {
+		return fmt.Errorf("%s: %w", name, ErrLimitExceeded)
+	}
+
+	for i := 0; i < n; i++ {
+		requests = append(requests, now)
+		l.pending[name] = append(l.pending[name], now)
+	}
+	l.requests[name] = requests
+
+	return nil
+}
+
+// Remaining returns the amount of requests left in the bucket right now
+func (l *Limiter) Remaining(name string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[name]
+	if !ok {
+		return 0
+	}
+	return b.Limit - len(l.prune(b, time.Now()))
+}
+
+// ResetAt returns the time at which the bucket will be fully available again,
+// zero if it already is
+func (l *Limiter) ResetAt(name string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[name]
+	if !ok {
+		return time.Time{}
+	}
+
+	requests := l.prune(b, time.Now())
+	if len(requests) == 0 {
+		return time.Time{}
+	}
+	return requests[len(requests)-1].Add(b.Window)
+}
+
+// AvailableAt returns the time at which n more requests will fit into the bucket,
+// it returns an error if n exceeds the bucket limit and can never fit
+func (l *Limiter) AvailableAt(name string, n int) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[name]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown bucket: %s", name)
+	}
+	if n > b.Limit {
+		return time.Time{}, fmt.Errorf(
+			"%s: %d request(s) exceed the limit of %d per %s",
+			name,
+			n,
+			b.Limit,
+			b.Window,
+		)
+	}
+
+	now := time.Now()
+	requests := l.prune(b, now)
+	expire := len(requests) + n - b.Limit
+	if expire <= 0 {
+		return now, nil
+	}
+	return requests[expire-1].Add(b.Window), nil
+}
+
+// Reset forgets all recorded requests of the bucket
+func (l *Limiter) Reset(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.requests, name)
+	delete(l.pending, name)
+	l.resets[name] = true
+}
+
+// Bucket returns a handle which limits requests for a single bucket,
+// it can be passed to clients which only need one bucket
+func (l *Limiter) Bucket(name string) *BucketLimiter {
+	return &BucketLimiter{limiter: l, name: name}
+}
+
+// Save merges the state of all buckets with the state file and writes it back
+func (l *Limiter) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.withFileLock(func() error {
+		state, err := l.read()
+		if err != nil {
+			return err
+		}
+		l.merge(state)
+
+		now := time.Now()
+		state = make(stateFile, len(l.requests))
+		for name, requests := range l.requests {
+			// State of buckets unknown to this limiter is kept as is.
+			if b, ok := l.buckets[name]; ok {
+				requests = l.prune(b, now)
+			}
+			if len(requests) > 0 {
+				state[name] = bucketState{Requests: requests}
+			}
+		}
+
+		content, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshalling rate limit state: %w", err)
+		}
+
+		return writeFileAtomic(l.path, content)
+	})
+	if err != nil {
+		return fmt.Errorf("saving rate limit state: %w", err)
+	}
+
+	l.pending = make(map[string][]time.Time, len(l.buckets))
+	l.resets = make(map[string]bool, len(l.buckets))
+
+	return nil
+}
+
+// BucketLimiter limits requests for a single bucket of a Limiter
+type BucketLimiter struct {
+	limiter *Limiter
+	name    string
+}
+
+// Take records n requests, see Limiter.Take
+func (b *BucketLimiter) Take(n int) error {
+	return b.limiter.Take(b.name, n)
+}
+
+type stateFile map[string]bucketState
+
+type bucketState struct {
+	Requests []time.Time `json:"requests"`
+}
+
+// withFileLock runs fn while holding the advisory lock of the state file
+func (l *Limiter) withFileLock(fn func() error) error {
+	f, err := os.OpenFile(l.path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("opening lock file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("locking state file: %w", err)
+	}
+	defer func() { _ = unlockFile(f) }()
+
+	return fn()
+}
+
+// read reads the state file, a corrupted file is moved aside and treated as empty
+func (l *Limiter) read() (stateFile, error) {
+	content, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stateFile{}, nil
+		}
+		return nil, err
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(content, &state); err != nil {
+		backup := l.path + ".corrupt"
+		if err := os.Rename(l.path, backup); err != nil {
+			return nil, fmt.Errorf("moving corrupted state file: %w", err)
+		}
+		l.recovered = backup
+		return stateFile{}, nil
+	}
+
+	return state, nil
+}
+
+// merge replaces the known requests by the state file ones plus
+// the pending requests of this limiter, must be called with l.mu held
+func (l *Limiter) merge(state stateFile) {
+	requests := make(map[string][]time.Time, len(state)+len(l.pending))
+	for name, s := range state {
+		if !l.resets[name] {
+			requests[name] = append(requests[name], s.Requests...)
+		}
+	}
+	for name, pending := range l.pending {
+		requests[name] = append(requests[name], pending...)
+	}
+
+	for name := range requests {
+		sort.Slice(requests[name], func(i, j int) bool {
+			return requests[name][i].Before(requests[name][j])
+		})
+	}
+
+	l.requests = requests
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames it,
+// so a crash mid-write never leaves a partially written file behind
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("setting permissions of temporary file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// prune drops all requests outside of the bucket window, must be called with l.mu held
+func (l *Limiter) prune(b Bucket, now time.Time) []time.Time {
+	requests := l.requests[b.Name]
+
+	i := 0
+	for i < len(requests) && now.Sub(requests[i]) >= b.Window {
+		i++
+	}
+	requests = requests[i:]
+
+	l.requests[b.Name] = requests
+	return requests
+}
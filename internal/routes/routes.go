@@ -0,0 +1,354 @@
+// Routes items to destinations, sheet tabs with their own column layout and total cells
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/devusSs/steamquery/internal/config"
+	"github.com/devusSs/steamquery/internal/steam/filter"
+	"github.com/devusSs/steamquery/pkg/steam"
+)
+
+var (
+	cellPattern   = regexp.MustCompile(`^([A-Z]{1,3})([1-9][0-9]*)$`)
+	columnPattern = regexp.MustCompile(`^[A-Z]{1,3}$`)
+)
+
+// Destination receives all items matching any of its rules,
+// a destination without rules is the catch-all for items matching no other destination
+//
+// Unset layout fields use the ones of the config, total cells are optional.
+type Destination struct {
+	Name               string        `json:"name"`
+	Sheet              string        `json:"sheet"`
+	Match              []filter.Rule `json:"match,omitempty"`
+	StartingRow        uint          `json:"starting_row,omitempty"`
+	ItemColumn         string        `json:"item_column,omitempty"`
+	AmountColumn       string        `json:"amount_column,omitempty"`
+	SinglePriceColumn  string        `json:"single_price_column,omitempty"`
+	TotalPriceColumn   string        `json:"total_price_column,omitempty"`
+	OverrideColumn     string        `json:"override_column,omitempty"`
+	CostBasisColumn    string        `json:"cost_basis_column,omitempty"`
+	ProfitColumn       string        `json:"profit_column,omitempty"`
+	PurchaseDateColumn string        `json:"purchase_date_column,omitempty"`
+	NotesColumn        string        `json:"notes_column,omitempty"`
+	TotalValueCell     string        `json:"total_value_cell,omitempty"`
+	DifferenceCell     string        `json:"difference_cell,omitempty"`
+}
+
+// CatchAll returns true if the destination has no rules
+func (d Destination) CatchAll() bool {
+	return len(d.Match) == 0
+}
+
+// WithDefaults returns the destination with unset layout fields taken from cfg
+func (d Destination) WithDefaults(cfg *config.Config) Destination {
+	if d.StartingRow == 0 {
+		d.StartingRow = cfg.StartingRow
+	}
+	defaults := []struct {
+		value *string
+		def   string
+	}{
+		{&d.ItemColumn, cfg.ItemColumn},
+		{&d.AmountColumn, cfg.AmountColumn},
+		{&d.SinglePriceColumn, cfg.SinglePriceColumn},
+		{&d.TotalPriceColumn, cfg.TotalPriceColumn},
+		{&d.OverrideColumn, cfg.OverrideColumn},
+		{&d.CostBasisColumn, cfg.CostBasisColumn},
+		{&d.ProfitColumn, cfg.ProfitColumn},
+		{&d.PurchaseDateColumn, cfg.PurchaseDateColumn},
+		{&d.NotesColumn, cfg.NotesColumn},
+	}
+	for _, v := range defaults {
+		if *v.value == "" {
+			*v.value = v.def
+		}
+	}
+	return d
+}
+
+// MainName is the name of the destination returned by Main, it is reserved in routes files
+const MainName = "main"
+
+// Main returns the destination described by the config itself, it receives all items
+// without a routes file and all unrouted items if there is no catch-all destination
+//
+// Its total cells are left empty since the config total cells hold the total of all destinations.
+func Main(cfg *config.Config) Destination {
+	return Destination{Name: MainName}.WithDefaults(cfg)
+}
+
+// Routes holds all destinations in order of precedence
+type Routes struct {
+	Destinations []Destination `json:"destinations"`
+}
+
+// Load reads a routes file, an empty path returns no destinations
+//
+// The routes have to be validated against the config before use, see Validate.
+func Load(filePath string) (*Routes, error) {
+	r := &Routes{}
+	if filePath == "" {
+		return r, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening routes file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(r); err != nil {
+		return nil, fmt.Errorf("error decoding routes file: %w", err)
+	}
+
+	return r, nil
+}
+
+// Validate checks all destinations, normalizes their cells and columns and compiles their rules,
+// destinations must not overlap each other or the layout and cells of cfg
+func (r *Routes) Validate(cfg *config.Config) error {
+	var problems []string
+	addProblem := func(d Destination, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("destination \"%s\": %s", d.Name, fmt.Sprintf(format, args...)))
+	}
+
+	names := make(map[string]bool, len(r.Destinations))
+	catchAll := ""
+	for i := range r.Destinations {
+		d := &r.Destinations[i]
+
+		if d.Name == "" {
+			problems = append(problems, fmt.Sprintf("destination %d: name is empty", i+1))
+			continue
+		}
+		if d.Name == MainName {
+			addProblem(*d, "name is reserved for the layout of the config")
+		}
+		if names[d.Name] {
+			addProblem(*d, "duplicate name")
+		}
+		names[d.Name] = true
+
+		if d.CatchAll() {
+			if catchAll != "" {
+				addProblem(*d, "only one destination without rules is allowed, \"%s\" already is the catch-all", catchAll)
+			}
+			catchAll = d.Name
+		}
+
+		for j := range d.Match {
+			if err := d.Match[j].Validate(); err != nil {
+				addProblem(*d, "rule %d: %v", j+1, err)
+			}
+		}
+
+		usedColumns := make(map[string]string)
+		for _, column := range []struct {
+			key   string
+			value *string
+		}{
+			{"item_column", &d.ItemColumn},
+			{"amount_column", &d.AmountColumn},
+			{"single_price_column", &d.SinglePriceColumn},
+			{"total_price_column", &d.TotalPriceColumn},
+			{"override_column", &d.OverrideColumn},
+			{"cost_basis_column", &d.CostBasisColumn},
+			{"profit_column", &d.ProfitColumn},
+			{"purchase_date_column", &d.PurchaseDateColumn},
+			{"notes_column", &d.NotesColumn},
+		} {
+			*column.value = strings.ToUpper(strings.TrimSpace(*column.value))
+			if *column.value == "" {
+				continue
+			}
+			if !columnPattern.MatchString(*column.value) {
+				addProblem(*d, "%s \"%s\" is not a column letter (e.g. \"B\")", column.key, *column.value)
+				continue
+			}
+			if other, ok := usedColumns[*column.value]; ok {
+				addProblem(*d, "%s %s is already used by %s", column.key, *column.value, other)
+			}
+			usedColumns[*column.value] = column.key
+		}
+
+		for _, cell := range []struct {
+			key   string
+			value *string
+		}{
+			{"total_value_cell", &d.TotalValueCell},
+			{"difference_cell", &d.DifferenceCell},
+		} {
+			*cell.value = strings.ToUpper(strings.TrimSpace(*cell.value))
+			if *cell.value != "" && !cellPattern.MatchString(*cell.value) {
+				addProblem(*d, "%s \"%s\" is not a cell in A1 notation (e.g. \"M4\")", cell.key, *cell.value)
+			}
+		}
+	}
+
+	// Overlaps are only meaningful once all cells and columns are valid.
+	if len(problems) == 0 {
+		problems = r.overlaps(cfg)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// area is the item columns of a destination or of the config layout, its rows are unbounded
+type area struct {
+	owner       string
+	sheet       string
+	startingRow uint
+	columns     []string
+}
+
+// cellRef is a single cell written by a destination or the config
+type cellRef struct {
+	owner string
+	key   string
+	sheet string
+	value string
+}
+
+// overlaps returns a problem for every area or cell written more than once, the later
+// write of a batch would silently overwrite the earlier one
+//
+// An empty sheet is the first sheet, which also holds the config layout and cells.
+func (r *Routes) overlaps(cfg *config.Config) []string {
+	// The config comes first, so problems are reported for the destinations.
+	var areas []area
+	if _, ok := r.CatchAll(); !ok {
+		areas = append(areas, destinationArea("the config layout", Main(cfg)))
+	}
+	cells := []cellRef{
+		{"the config", "last_updated_cell", "", cfg.LastUpdatedCell},
+		{"the config", "error_cell", "", cfg.ErrorCell},
+		{"the config", "total_value_cell", "", cfg.TotalValueCell},
+		{"the config", "difference_cell", "", cfg.DifferenceCell},
+	}
+	for _, d := range r.Destinations {
+		owner := fmt.Sprintf("destination \"%s\"", d.Name)
+		areas = append(areas, destinationArea(owner, d.WithDefaults(cfg)))
+		for _, c := range []cellRef{
+			{owner, "total_value_cell", d.Sheet, d.TotalValueCell},
+			{owner, "difference_cell", d.Sheet, d.DifferenceCell},
+		} {
+			if c.value != "" {
+				cells = append(cells, c)
+			}
+		}
+	}
+
+	var problems []string
+	for i, a := range areas {
+		for _, other := range areas[:i] {
+			if a.sheet != other.sheet {
+				continue
+			}
+			for _, column := range a.columns {
+				if slices.Contains(other.columns, column) {
+					problems = append(problems, fmt.Sprintf(
+						"%s: column %s overlaps the item rows of %s on %s",
+						a.owner, column, other.owner, sheetName(a.sheet),
+					))
+				}
+			}
+		}
+	}
+
+	for i, c := range cells {
+		for _, other := range cells[:i] {
+			if c.sheet == other.sheet && c.value == other.value {
+				problems = append(problems, fmt.Sprintf(
+					"%s: %s %s is already used by %s of %s on %s",
+					c.owner, c.key, c.value, other.key, other.owner, sheetName(c.sheet),
+				))
+			}
+		}
+
+		match := cellPattern.FindStringSubmatch(c.value)
+		if match == nil {
+			continue
+		}
+		row, _ := strconv.ParseUint(match[2], 10, 64)
+		for _, a := range areas {
+			if c.sheet == a.sheet && slices.Contains(a.columns, match[1]) && uint(row) >= a.startingRow {
+				problems = append(problems, fmt.Sprintf(
+					"%s: %s %s collides with the item rows of %s (starting at row %d) on %s",
+					c.owner, c.key, c.value, a.owner, a.startingRow, sheetName(c.sheet),
+				))
+			}
+		}
+	}
+
+	return problems
+}
+
+func destinationArea(owner string, d Destination) area {
+	a := area{owner: owner, sheet: d.Sheet, startingRow: d.StartingRow}
+	for _, column := range []string{
+		d.ItemColumn,
+		d.AmountColumn,
+		d.SinglePriceColumn,
+		d.TotalPriceColumn,
+		d.OverrideColumn,
+		d.CostBasisColumn,
+		d.ProfitColumn,
+		d.PurchaseDateColumn,
+		d.NotesColumn,
+	} {
+		if column != "" {
+			a.columns = append(a.columns, column)
+		}
+	}
+	return a
+}
+
+func sheetName(sheet string) string {
+	if sheet == "" {
+		return "the first sheet"
+	}
+	return fmt.Sprintf("sheet \"%s\"", sheet)
+}
+
+// CatchAll returns the catch-all destination, if any
+func (r *Routes) CatchAll() (Destination, bool) {
+	for _, d := range r.Destinations {
+		if d.CatchAll() {
+			return d, true
+		}
+	}
+	return Destination{}, false
+}
+
+// Route returns the name of the first destination with a rule matching the item,
+// else the name of the catch-all destination, empty if there is none
+func (r *Routes) Route(item steam.InventoryDescription) string {
+	for i := range r.Destinations {
+		d := &r.Destinations[i]
+		if d.CatchAll() {
+			continue
+		}
+		if ok, _ := filter.MatchAny(d.Match, item); ok {
+			return d.Name
+		}
+	}
+
+	if d, ok := r.CatchAll(); ok {
+		return d.Name
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+// Keeps track of the rows written to each spreadsheet column by the previous run,
+// so rows left over after the inventory shrank can be cleared
+package sheetstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultFileName is the name of the state file inside the logs directory
+const DefaultFileName = ".sheets.json"
+
+// Rows is an inclusive range of rows, it is empty if End is less than Start
+type Rows struct {
+	Start uint `json:"start"`
+	End   uint `json:"end"`
+}
+
+// Empty returns true if the range holds no rows
+func (r Rows) Empty() bool {
+	return r.End < r.Start
+}
+
+// Stale returns the parts of the previous range r which are not part of current
+func (r Rows) Stale(current Rows) []Rows {
+	if r.Empty() {
+		return nil
+	}
+	if current.Empty() {
+		return []Rows{r}
+	}
+
+	var stale []Rows
+	if r.Start < current.Start {
+		stale = append(stale, Rows{Start: r.Start, End: min(r.End, current.Start-1)})
+	}
+	if r.End > current.End {
+		stale = append(stale, Rows{Start: max(r.Start, current.End+1), End: r.End})
+	}
+	return stale
+}
+
+// State holds the written rows by spreadsheet, sheet and column
+type State struct {
+	path    string
+	written map[string]Rows
+}
+
+// Key returns the key of a column of a sheet (empty for the first sheet) of a spreadsheet
+func Key(spreadsheetID string, sheet string, column string) string {
+	return fmt.Sprintf("%s/%s/%s", spreadsheetID, sheet, column)
+}
+
+// Load reads the state file at path, a missing or corrupted file is an empty state
+func Load(path string) (*State, error) {
+	s := &State{path: path, written: make(map[string]Rows)}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("reading sheet state: %w", err)
+	}
+
+	// A corrupted state only means leftover rows are not cleared once.
+	if err := json.Unmarshal(content, &s.written); err != nil {
+		s.written = make(map[string]Rows)
+	}
+
+	return s, nil
+}
+
+// Rows returns the rows written to the column by the previous run
+func (s *State) Rows(key string) (Rows, bool) {
+	rows, ok := s.written[key]
+	return rows, ok
+}
+
+// SetRows records the rows written to the column
+func (s *State) SetRows(key string, rows Rows) {
+	s.written[key] = rows
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("creating sheet state directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(s.written, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling sheet state: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, content, 0644); err != nil {
+		return fmt.Errorf("writing sheet state: %w", err)
+	}
+
+	return nil
+}
@@ -5,40 +5,105 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/devusSs/steamquery/pkg/log"
 	"github.com/devusSs/steamquery/pkg/steam"
 )
 
-func LoadFilterOptions(filePath string) error {
+// Filter decides which inventory items are kept, it is loaded from a file or built in code
+//
+// Items are kept if they match any include rule (all items without include rules) and no exclude rule.
+// The value fields are applied after pricing by FilterByValue, zero values disable them.
+type Filter struct {
+	Tradable   bool   `json:"tradable"`
+	Marketable bool   `json:"marketable"`
+	Include    []Rule `json:"include,omitempty"`
+	Exclude    []Rule `json:"exclude,omitempty"`
+
+	MinUnitValue  float64 `json:"min_unit_value,omitempty"`
+	MinTotalValue float64 `json:"min_total_value,omitempty"`
+	MinShare      float64 `json:"min_share,omitempty"` // percent of the total value
+	Top           int     `json:"top,omitempty"`
+	// Removed items are collapsed into a single row instead of being dropped.
+	CollapseOther bool `json:"collapse_other,omitempty"`
+}
+
+// Default returns the filter used without a filter file, keeping marketable items only
+func Default() *Filter {
+	return &Filter{
+		Tradable:   false,
+		Marketable: true,
+	}
+}
+
+// Load reads a filter file, an empty path returns the Default filter
+func Load(filePath string) (*Filter, error) {
 	if filePath == "" {
-		filter = filterOptions{
-			Tradable:   false,
-			Marketable: true,
-		}
-		return nil
+		return Default(), nil
 	}
 
 	f, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("error opening filter file: %w", err)
+		return nil, fmt.Errorf("error opening filter file: %w", err)
 	}
 	defer f.Close()
 
-	if err := json.NewDecoder(f).Decode(&filter); err != nil {
-		return fmt.Errorf("error decoding filter file: %w", err)
+	var filter Filter
+	dec := json.NewDecoder(f)
+	// Typos in rules would silently keep or drop items.
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		return nil, fmt.Errorf("error decoding filter file: %w", err)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("error validating filter file: %w", err)
+	}
+
+	return &filter, nil
+}
+
+// Validate checks the filter and compiles the regular expressions of its rules,
+// filters built in code should be validated before use
+func (f *Filter) Validate() error {
+	for i := range f.Include {
+		if err := f.Include[i].Validate(); err != nil {
+			return fmt.Errorf("include rule %d: %w", i+1, err)
+		}
+	}
+	for i := range f.Exclude {
+		if err := f.Exclude[i].Validate(); err != nil {
+			return fmt.Errorf("exclude rule %d: %w", i+1, err)
+		}
+	}
+
+	if f.MinUnitValue < 0 {
+		return fmt.Errorf("min_unit_value must not be negative")
+	}
+	if f.MinTotalValue < 0 {
+		return fmt.Errorf("min_total_value must not be negative")
+	}
+	if f.MinShare < 0 || f.MinShare > 100 {
+		return fmt.Errorf("min_share must be between 0 and 100")
+	}
+	if f.Top < 0 {
+		return fmt.Errorf("top must not be negative")
 	}
 
 	return nil
 }
 
-func FilterInventoryResponse(
+// Apply drops all items not matching the filter,
+// the reason for every dropped item is logged to logger in debug mode (may be nil)
+func (f *Filter) Apply(
 	inv steam.SteamInventoryResponse,
+	logger *log.Logger,
 ) steam.SteamInventoryResponse {
 	r := steam.SteamInventoryResponse{}
 	for _, item := range inv.Descriptions {
-		if filter.Tradable && item.Tradable == 0 {
-			continue
-		}
-		if filter.Marketable && item.Marketable == 0 {
+		if reason, ok := f.Drop(item); ok {
+			if logger != nil {
+				logger.Debug("filter dropped %s: %s", item.MarketHashName, reason)
+			}
 			continue
 		}
 		r.Descriptions = append(r.Descriptions, item)
@@ -46,63 +111,50 @@ func FilterInventoryResponse(
 	return r
 }
 
-func GetFilterSettings() filterOptions {
-	return filter
-}
-
-func GetItemAmountMap(inv steam.SteamInventoryResponse) map[string]int {
-	m := make(map[string]int)
-	for _, item := range inv.Descriptions {
-		m[item.MarketHashName]++
-	}
-	return m
-}
-
-func AddItems(amountMap map[string]int, itemsFile string) (map[string]int, error) {
-	if itemsFile == "" {
-		return amountMap, nil
+// Drop returns the reason and true if the item should be dropped
+func (f *Filter) Drop(item steam.InventoryDescription) (string, bool) {
+	if f.Tradable && item.Tradable == 0 {
+		return "not tradable", true
 	}
-
-	f, err := os.Open(itemsFile)
-	if err != nil {
-		return nil, fmt.Errorf("item file not found: %w", err)
+	if f.Marketable && item.Marketable == 0 {
+		return "not marketable", true
 	}
-	defer f.Close()
 
-	var items itemFileStructure
-	if err := json.NewDecoder(f).Decode(&items); err != nil {
-		return nil, fmt.Errorf("could not unmarshal items: %w", err)
+	if len(f.Include) > 0 {
+		if ok, reason := MatchAny(f.Include, item); !ok {
+			return fmt.Sprintf("matches no include rule (%s)", reason), true
+		}
 	}
 
-	for _, item := range items.Items {
-		if _, ok := amountMap[item.MarketHashName]; ok {
-			amountMap[item.MarketHashName] += item.Amount
-			continue
+	for i := range f.Exclude {
+		if ok, reason := f.Exclude[i].Match(item); ok {
+			return fmt.Sprintf("matches exclude rule %d (%s)", i+1, reason), true
 		}
-		amountMap[item.MarketHashName] = item.Amount
 	}
 
-	return amountMap, nil
+	return "", false
 }
 
-type filterOptions struct {
-	Tradable   bool `json:"tradable"`
-	Marketable bool `json:"marketable"`
+func (f *Filter) String() string {
+	return fmt.Sprintf(
+		"tradable: %t, marketable: %t, include rules: %d, exclude rules: %d, "+
+			"min unit value: %.2f, min total value: %.2f, min share: %.2f%%, top: %d, collapse other: %t",
+		f.Tradable,
+		f.Marketable,
+		len(f.Include),
+		len(f.Exclude),
+		f.MinUnitValue,
+		f.MinTotalValue,
+		f.MinShare,
+		f.Top,
+		f.CollapseOther,
+	)
 }
 
-func (f filterOptions) String() string {
-	return fmt.Sprintf("tradable: %t, marketable: %t", f.Tradable, f.Marketable)
-}
-
-var (
-	filter filterOptions
-)
-
-type itemFileStructure struct {
-	Items []item `json:"items"`
-}
-
-type item struct {
-	MarketHashName string `json:"market_hash_name"`
-	Amount         int    `json:"amount"`
+func GetItemAmountMap(inv steam.SteamInventoryResponse) map[string]int {
+	m := make(map[string]int)
+	for _, item := range inv.Descriptions {
+		m[item.MarketHashName]++
+	}
+	return m
 }
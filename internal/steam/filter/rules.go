@@ -0,0 +1,236 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/devusSs/steamquery/pkg/steam"
+)
+
+// Steam inventory tag categories used by rules
+const (
+	tagType       = "Type"
+	tagRarity     = "Rarity"
+	tagExterior   = "Exterior"
+	tagWeapon     = "Weapon"
+	tagCollection = "ItemSet"
+	tagQuality    = "Quality"
+)
+
+// tagCategories maps the rule keys to Steam inventory tag categories
+var tagCategories = map[string]string{
+	"type":       tagType,
+	"rarity":     tagRarity,
+	"exterior":   tagExterior,
+	"weapon":     tagWeapon,
+	"collection": tagCollection,
+	"quality":    tagQuality,
+}
+
+// Tag returns the (localized) tag of the item for a rule key like "rarity",
+// false if the key is unknown or the item has no such tag
+func Tag(item steam.InventoryDescription, key string) (string, bool) {
+	category, ok := tagCategories[key]
+	if !ok {
+		return "", false
+	}
+	tag, ok := findTag(item, category)
+	if !ok {
+		return "", false
+	}
+	return tag.LocalizedTagName, true
+}
+
+// Rule matches items if all of its conditions match, a rule without conditions matches every item
+//
+// Tag conditions match if the item has any of the given tags (case insensitive, localized or internal name),
+// All and Any combine nested rules with AND or OR
+type Rule struct {
+	Type            []string `json:"type,omitempty"`
+	Rarity          []string `json:"rarity,omitempty"`
+	Exterior        []string `json:"exterior,omitempty"`
+	Weapon          []string `json:"weapon,omitempty"`
+	Collection      []string `json:"collection,omitempty"`
+	Quality         []string `json:"quality,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	NameRegex       string   `json:"name_regex,omitempty"`
+	StatTrak        *bool    `json:"stattrak,omitempty"`
+	Souvenir        *bool    `json:"souvenir,omitempty"`
+	MarketHashNames []string `json:"market_hash_names,omitempty"`
+	All             []Rule   `json:"all,omitempty"`
+	Any             []Rule   `json:"any,omitempty"`
+
+	// compiled by Validate
+	nameRegex *regexp.Regexp
+}
+
+// Validate checks the rule and its nested rules and compiles their regular expressions
+func (r *Rule) Validate() error {
+	if r.Name != "" {
+		if _, err := path.Match(r.Name, ""); err != nil {
+			return fmt.Errorf("invalid name pattern %q: %w", r.Name, err)
+		}
+	}
+
+	if r.NameRegex != "" {
+		re, err := regexp.Compile(r.NameRegex)
+		if err != nil {
+			return fmt.Errorf("invalid name regex %q: %w", r.NameRegex, err)
+		}
+		r.nameRegex = re
+	}
+
+	for i := range r.All {
+		if err := r.All[i].Validate(); err != nil {
+			return fmt.Errorf("all rule %d: %w", i+1, err)
+		}
+	}
+	for i := range r.Any {
+		if err := r.Any[i].Validate(); err != nil {
+			return fmt.Errorf("any rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Match returns whether the item matches the rule, along with
+// the matched conditions if it does or the first failed condition if it does not
+func (r *Rule) Match(item steam.InventoryDescription) (bool, string) {
+	var matched []string
+
+	tags := []struct {
+		key      string
+		category string
+		values   []string
+	}{
+		{"type", tagType, r.Type},
+		{"rarity", tagRarity, r.Rarity},
+		{"exterior", tagExterior, r.Exterior},
+		{"weapon", tagWeapon, r.Weapon},
+		{"collection", tagCollection, r.Collection},
+		{"quality", tagQuality, r.Quality},
+	}
+	for _, t := range tags {
+		if len(t.values) == 0 {
+			continue
+		}
+		tag, ok := findTag(item, t.category)
+		if !ok {
+			return false, fmt.Sprintf("has no %s", t.key)
+		}
+		if !matchTag(tag, t.values) {
+			return false, fmt.Sprintf("%s %q not in %v", t.key, tag.LocalizedTagName, t.values)
+		}
+		matched = append(matched, fmt.Sprintf("%s is %q", t.key, tag.LocalizedTagName))
+	}
+
+	name := item.MarketHashName
+
+	if r.Name != "" {
+		// Invalid patterns never match, Validate reports them.
+		if ok, _ := path.Match(r.Name, name); !ok {
+			return false, fmt.Sprintf("name does not match %q", r.Name)
+		}
+		matched = append(matched, fmt.Sprintf("name matches %q", r.Name))
+	}
+
+	if r.NameRegex != "" {
+		re := r.nameRegex
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(r.NameRegex); err != nil {
+				return false, fmt.Sprintf("invalid name regex %q", r.NameRegex)
+			}
+		}
+		if !re.MatchString(name) {
+			return false, fmt.Sprintf("name does not match regex %q", r.NameRegex)
+		}
+		matched = append(matched, fmt.Sprintf("name matches regex %q", r.NameRegex))
+	}
+
+	if r.StatTrak != nil {
+		if isStatTrak(item) != *r.StatTrak {
+			return false, fmt.Sprintf("stattrak is not %t", *r.StatTrak)
+		}
+		matched = append(matched, fmt.Sprintf("stattrak is %t", *r.StatTrak))
+	}
+
+	if r.Souvenir != nil {
+		if isSouvenir(item) != *r.Souvenir {
+			return false, fmt.Sprintf("souvenir is not %t", *r.Souvenir)
+		}
+		matched = append(matched, fmt.Sprintf("souvenir is %t", *r.Souvenir))
+	}
+
+	if len(r.MarketHashNames) > 0 {
+		if !slices.Contains(r.MarketHashNames, name) {
+			return false, "name not in market_hash_names"
+		}
+		matched = append(matched, "name in market_hash_names")
+	}
+
+	for i := range r.All {
+		ok, reason := r.All[i].Match(item)
+		if !ok {
+			return false, fmt.Sprintf("all rule %d: %s", i+1, reason)
+		}
+		matched = append(matched, fmt.Sprintf("all rule %d: %s", i+1, reason))
+	}
+
+	if len(r.Any) > 0 {
+		ok, reason := MatchAny(r.Any, item)
+		if !ok {
+			return false, fmt.Sprintf("no any rule matches (%s)", reason)
+		}
+		matched = append(matched, reason)
+	}
+
+	if len(matched) == 0 {
+		return true, "empty rule"
+	}
+	return true, strings.Join(matched, " and ")
+}
+
+// MatchAny returns whether any of the rules matches the item, along with
+// the matching rule or the reasons all of them failed
+func MatchAny(rules []Rule, item steam.InventoryDescription) (bool, string) {
+	reasons := make([]string, 0, len(rules))
+	for i := range rules {
+		ok, reason := rules[i].Match(item)
+		if ok {
+			return true, fmt.Sprintf("rule %d: %s", i+1, reason)
+		}
+		reasons = append(reasons, fmt.Sprintf("rule %d: %s", i+1, reason))
+	}
+	return false, strings.Join(reasons, "; ")
+}
+
+func findTag(item steam.InventoryDescription, category string) (steam.InventoryTag, bool) {
+	for _, tag := range item.Tags {
+		if tag.Category == category {
+			return tag, true
+		}
+	}
+	return steam.InventoryTag{}, false
+}
+
+func matchTag(tag steam.InventoryTag, values []string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, tag.LocalizedTagName) || strings.EqualFold(v, tag.InternalName) {
+			return true
+		}
+	}
+	return false
+}
+
+func isStatTrak(item steam.InventoryDescription) bool {
+	return strings.Contains(item.MarketHashName, "StatTrak™")
+}
+
+func isSouvenir(item steam.InventoryDescription) bool {
+	return strings.HasPrefix(item.MarketHashName, "Souvenir ")
+}
@@ -0,0 +1,68 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/devusSs/steamquery/pkg/log"
+)
+
+// PricedItem is an item which has already been priced, used by value filters
+type PricedItem interface {
+	Name() string
+	UnitValue() float64
+	TotalValue() float64
+}
+
+// FilterByValue splits the items into the ones passing the value fields of f
+// and the removed ones, the reason for every removed item is logged to logger in debug mode (may be nil)
+//
+// Items below the minimum unit value, total value or share of the total value of all items are removed first,
+// then only the top items by total value are kept.
+func FilterByValue[T PricedItem](f *Filter, items []T, logger *log.Logger) ([]T, []T) {
+	var kept, removed []T
+	remove := func(item T, format string, args ...interface{}) {
+		if logger != nil {
+			logger.Debug("value filter removed %s: %s", item.Name(), fmt.Sprintf(format, args...))
+		}
+		removed = append(removed, item)
+	}
+
+	var total float64
+	for _, item := range items {
+		total += item.TotalValue()
+	}
+
+	for _, item := range items {
+		share := 0.0
+		if total > 0 {
+			share = item.TotalValue() / total * 100
+		}
+
+		switch {
+		case item.UnitValue() < f.MinUnitValue:
+			remove(item, "unit value %.2f below %.2f", item.UnitValue(), f.MinUnitValue)
+		case item.TotalValue() < f.MinTotalValue:
+			remove(item, "total value %.2f below %.2f", item.TotalValue(), f.MinTotalValue)
+		case share < f.MinShare:
+			remove(item, "share %.2f%% below %.2f%%", share, f.MinShare)
+		default:
+			kept = append(kept, item)
+		}
+	}
+
+	if f.Top > 0 && len(kept) > f.Top {
+		sort.SliceStable(kept, func(i, j int) bool {
+			if kept[i].TotalValue() != kept[j].TotalValue() {
+				return kept[i].TotalValue() > kept[j].TotalValue()
+			}
+			return kept[i].Name() < kept[j].Name()
+		})
+		for _, item := range kept[f.Top:] {
+			remove(item, "not in top %d by total value", f.Top)
+		}
+		kept = kept[:f.Top]
+	}
+
+	return kept, removed
+}
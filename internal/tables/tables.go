@@ -3,6 +3,10 @@ package tables
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
@@ -14,10 +18,19 @@ type SpreadsheetService struct {
 }
 
 func NewSpreadsheetService(gCloudConfPath, spreadsheetID string) (*SpreadsheetService, error) {
+	return newSpreadsheetService(option.WithCredentialsFile(gCloudConfPath), spreadsheetID)
+}
+
+// NewSpreadsheetServiceFromJSON uses the contents of a Google credentials file instead of its path
+func NewSpreadsheetServiceFromJSON(gCloudConf []byte, spreadsheetID string) (*SpreadsheetService, error) {
+	return newSpreadsheetService(option.WithCredentialsJSON(gCloudConf), spreadsheetID)
+}
+
+func newSpreadsheetService(credentials option.ClientOption, spreadsheetID string) (*SpreadsheetService, error) {
 	ctx := context.Background()
 	srv, err := sheets.NewService(
 		ctx,
-		option.WithCredentialsFile(gCloudConfPath),
+		credentials,
 		option.WithScopes(sheets.SpreadsheetsScope),
 	)
 	if err != nil {
@@ -33,15 +46,29 @@ func NewSpreadsheetService(gCloudConfPath, spreadsheetID string) (*SpreadsheetSe
 	return c, nil
 }
 
+// Cell prefixes a cell in A1 notation with the given sheet (tab),
+// an empty sheet refers to the first sheet
+func Cell(sheet string, cell string) string {
+	if sheet == "" {
+		return cell
+	}
+	return fmt.Sprintf("'%s'!%s", strings.ReplaceAll(sheet, "'", "''"), cell)
+}
+
 func (s *SpreadsheetService) Test() error {
 	_, err := s.service.Spreadsheets.Values.Get(s.spreadsheetID, "A1:Z1").Do()
 	return err
 }
 
+// Range returns the range from startCell to endCell in A1 notation
+func Range(startCell string, endCell string) string {
+	return fmt.Sprintf("%s:%s", startCell, endCell)
+}
+
 func (s *SpreadsheetService) Read(
 	startCell, endCell string,
 ) (*sheets.ValueRange, error) {
-	values, err := s.service.Spreadsheets.Values.Get(s.spreadsheetID, fmt.Sprintf("%s:%s", startCell, endCell)).
+	values, err := s.service.Spreadsheets.Values.Get(s.spreadsheetID, Range(startCell, endCell)).
 		Do()
 	return values, err
 }
@@ -50,8 +77,442 @@ func (s *SpreadsheetService) Write(startCell string, endCell string, values [][]
 	valueRange := &sheets.ValueRange{
 		Values: values,
 	}
-	_, err := s.service.Spreadsheets.Values.Update(s.spreadsheetID, fmt.Sprintf("%s:%s", startCell, endCell), valueRange).
+	_, err := s.service.Spreadsheets.Values.Update(s.spreadsheetID, Range(startCell, endCell), valueRange).
 		ValueInputOption("USER_ENTERED").
 		Do()
 	return err
 }
+
+// BatchRead reads all ranges with a single request,
+// the value ranges are returned in the order of the ranges
+func (s *SpreadsheetService) BatchRead(ranges ...string) ([]*sheets.ValueRange, error) {
+	return s.batchRead(ranges, "FORMATTED_VALUE")
+}
+
+// BatchReadUnformatted is BatchRead, but numbers are returned as float64 regardless of their formatting
+func (s *SpreadsheetService) BatchReadUnformatted(ranges ...string) ([]*sheets.ValueRange, error) {
+	return s.batchRead(ranges, "UNFORMATTED_VALUE")
+}
+
+func (s *SpreadsheetService) batchRead(ranges []string, valueRenderOption string) ([]*sheets.ValueRange, error) {
+	values, err := s.service.Spreadsheets.Values.BatchGet(s.spreadsheetID).
+		Ranges(ranges...).
+		ValueRenderOption(valueRenderOption).
+		Do()
+	if err != nil {
+		return nil, err
+	}
+	return values.ValueRanges, nil
+}
+
+// NumberFormat applies Pattern (e.g. #,##0.00"€") to the cells from StartCell to EndCell
+// of Sheet (empty for the first sheet)
+type NumberFormat struct {
+	Sheet     string
+	StartCell string
+	EndCell   string
+	Type      string
+	Pattern   string
+}
+
+// Rows is the inclusive range of rows from Start to End (counting from 1 like A1 notation)
+// of Sheet (empty for the first sheet)
+type Rows struct {
+	Sheet string
+	Start int64
+	End   int64
+}
+
+// Columns is the inclusive range of columns from Start to End (letters like A1 notation)
+// of Sheet (empty for the first sheet)
+type Columns struct {
+	Sheet string
+	Start string
+	End   string
+}
+
+// Update holds all changes to a spreadsheet, see Apply
+type Update struct {
+	// DeleteRows and DeleteColumns are deleted before all other changes,
+	// the rows below and columns to the right move up and left
+	DeleteRows    []Rows
+	DeleteColumns []Columns
+	// Values are written to their ranges, strings are parsed like user input (e.g. 12,34€ becomes
+	// a number) and time.Time values are written as date and time numbers
+	//
+	// Sheets too small for the values are grown by appending rows and columns.
+	Values []*sheets.ValueRange
+	// Clear holds ranges whose values (not the formatting) are cleared
+	Clear   []string
+	Formats []NumberFormat
+}
+
+// Empty returns true if the update changes nothing
+func (u Update) Empty() bool {
+	return len(u.DeleteRows) == 0 && len(u.DeleteColumns) == 0 &&
+		len(u.Values) == 0 && len(u.Clear) == 0 && len(u.Formats) == 0
+}
+
+// Apply sends all changes of the update, the sheets are looked up first
+//
+// Rows and columns are deleted, appended and ranges cleared with a single request,
+// then values are written and formats applied with one request each.
+func (s *SpreadsheetService) Apply(u Update) error {
+	if u.Empty() {
+		return nil
+	}
+
+	var names []string
+	for _, rows := range u.DeleteRows {
+		names = append(names, rows.Sheet)
+	}
+	for _, columns := range u.DeleteColumns {
+		names = append(names, columns.Sheet)
+	}
+	for _, rng := range u.Clear {
+		sheet, _ := splitSheet(rng)
+		names = append(names, sheet)
+	}
+	for _, values := range u.Values {
+		sheet, _ := splitSheet(values.Range)
+		names = append(names, sheet)
+	}
+	for _, f := range u.Formats {
+		names = append(names, f.Sheet)
+	}
+
+	grids, err := s.sheetGrids(names)
+	if err != nil {
+		return fmt.Errorf("getting sheets: %w", err)
+	}
+	ids := make(map[string]int64, len(grids))
+	for name, grid := range grids {
+		ids[name] = grid.id
+	}
+
+	requests := make([]*sheets.Request, 0, len(u.DeleteRows)+len(u.DeleteColumns)+len(u.Clear))
+
+	deleteDimension := func(sheet string, dimension string, start int64, end int64) error {
+		grid, ok := grids[sheet]
+		if !ok {
+			return fmt.Errorf("sheet %s does not exist", sheet)
+		}
+		if dimension == "ROWS" {
+			grid.rows -= end - start
+		} else {
+			grid.columns -= end - start
+		}
+		requests = append(requests, &sheets.Request{
+			DeleteDimension: &sheets.DeleteDimensionRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    grid.id,
+					Dimension:  dimension,
+					StartIndex: start,
+					EndIndex:   end,
+					// Zero values (e.g. row 1) would be omitted otherwise.
+					ForceSendFields: []string{"SheetId", "StartIndex"},
+				},
+			},
+		})
+		return nil
+	}
+	for _, rows := range u.DeleteRows {
+		if err := deleteDimension(rows.Sheet, "ROWS", rows.Start-1, rows.End); err != nil {
+			return err
+		}
+	}
+	for _, columns := range u.DeleteColumns {
+		start, _ := startIndex(columns.Start)
+		end, _ := startIndex(columns.End)
+		if err := deleteDimension(columns.Sheet, "COLUMNS", start, end+1); err != nil {
+			return err
+		}
+	}
+
+	// The values are placed for the sheets after the deletions, so they are grown afterwards.
+	appendDimension := func(id int64, dimension string, length int64) {
+		requests = append(requests, &sheets.Request{
+			AppendDimension: &sheets.AppendDimensionRequest{
+				SheetId:   id,
+				Dimension: dimension,
+				Length:    length,
+				// A zero value (e.g. the first sheet) would be omitted otherwise.
+				ForceSendFields: []string{"SheetId"},
+			},
+		})
+	}
+	for _, values := range u.Values {
+		sheet, cells := splitSheet(values.Range)
+		startCell, _, _ := strings.Cut(cells, ":")
+		column, row := startIndex(startCell)
+
+		columns := 0
+		for _, r := range values.Values {
+			columns = max(columns, len(r))
+		}
+
+		grid, ok := grids[sheet]
+		if !ok {
+			return fmt.Errorf("sheet %s does not exist", sheet)
+		}
+		if end := row + int64(len(values.Values)); end > grid.rows {
+			appendDimension(grid.id, "ROWS", end-grid.rows)
+			grid.rows = end
+		}
+		if end := column + int64(columns); end > grid.columns {
+			appendDimension(grid.id, "COLUMNS", end-grid.columns)
+			grid.columns = end
+		}
+	}
+
+	for _, rng := range u.Clear {
+		grid, err := parseRange(ids, rng)
+		if err != nil {
+			return err
+		}
+		requests = append(requests, &sheets.Request{
+			UpdateCells: &sheets.UpdateCellsRequest{
+				Range:  grid,
+				Fields: "userEnteredValue",
+			},
+		})
+	}
+
+	if err := s.batchUpdate(requests); err != nil {
+		return fmt.Errorf("deleting and clearing: %w", err)
+	}
+
+	if err := s.batchWrite(u.Values); err != nil {
+		return fmt.Errorf("writing values: %w", err)
+	}
+
+	requests = make([]*sheets.Request, 0, len(u.Formats))
+	for _, f := range u.Formats {
+		id, ok := ids[f.Sheet]
+		if !ok {
+			return fmt.Errorf("sheet %s does not exist", f.Sheet)
+		}
+
+		grid, err := gridRange(id, f.StartCell, f.EndCell)
+		if err != nil {
+			return err
+		}
+
+		requests = append(requests, &sheets.Request{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range: grid,
+				Cell: &sheets.CellData{
+					UserEnteredFormat: &sheets.CellFormat{
+						NumberFormat: &sheets.NumberFormat{
+							Type:    f.Type,
+							Pattern: f.Pattern,
+						},
+					},
+				},
+				Fields: "userEnteredFormat.numberFormat",
+			},
+		})
+	}
+
+	if err := s.batchUpdate(requests); err != nil {
+		return fmt.Errorf("applying formats: %w", err)
+	}
+
+	return nil
+}
+
+// batchUpdate sends the requests with a single request, nothing is sent without requests
+func (s *SpreadsheetService) batchUpdate(requests []*sheets.Request) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	_, err := s.service.Spreadsheets.BatchUpdate(s.spreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: requests,
+	}).Do()
+	return err
+}
+
+// batchWrite writes all value ranges with a single request, values are parsed like user input
+func (s *SpreadsheetService) batchWrite(valueRanges []*sheets.ValueRange) error {
+	if len(valueRanges) == 0 {
+		return nil
+	}
+
+	data := make([]*sheets.ValueRange, 0, len(valueRanges))
+	for _, valueRange := range valueRanges {
+		values := make([][]interface{}, 0, len(valueRange.Values))
+		for _, row := range valueRange.Values {
+			converted := make([]interface{}, 0, len(row))
+			for _, value := range row {
+				converted = append(converted, userEnteredValue(value))
+			}
+			values = append(values, converted)
+		}
+		data = append(data, &sheets.ValueRange{Range: valueRange.Range, Values: values})
+	}
+
+	_, err := s.service.Spreadsheets.Values.BatchUpdate(s.spreadsheetID, &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "USER_ENTERED",
+		Data:             data,
+	}).Do()
+	return err
+}
+
+// sheetsEpoch is day zero of date and time numbers
+var sheetsEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// userEnteredValue converts time.Time values to date and time numbers,
+// all other values are sent as they are
+func userEnteredValue(value interface{}) interface{} {
+	t, ok := value.(time.Time)
+	if !ok {
+		return value
+	}
+
+	// The wall clock time is kept, like a timestamp entered by a user.
+	wall := time.Date(
+		t.Year(), t.Month(), t.Day(),
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
+		time.UTC,
+	)
+	return wall.Sub(sheetsEpoch).Hours() / 24
+}
+
+// parseRange converts a range from StartCell to EndCell in A1 notation,
+// optionally prefixed by a sheet (see Cell), to a grid range
+func parseRange(ids map[string]int64, rng string) (*sheets.GridRange, error) {
+	sheet, cells := splitSheet(rng)
+
+	id, ok := ids[sheet]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s does not exist", sheet)
+	}
+
+	startCell, endCell, ok := strings.Cut(cells, ":")
+	if !ok {
+		endCell = startCell
+	}
+
+	return gridRange(id, startCell, endCell)
+}
+
+// splitSheet splits a range in A1 notation into its sheet (empty for none) and its cells
+func splitSheet(rng string) (string, string) {
+	if !strings.HasPrefix(rng, "'") {
+		return "", rng
+	}
+
+	end := strings.LastIndex(rng, "'!")
+	if end < 1 {
+		return "", rng
+	}
+
+	return strings.ReplaceAll(rng[1:end], "''", "'"), rng[end+2:]
+}
+
+// sheetGrid is the ID and size of a sheet
+type sheetGrid struct {
+	id      int64
+	rows    int64
+	columns int64
+}
+
+// sheetGrids returns the sheets by title, the first sheet is also available by "",
+// they are only looked up if names is not empty
+func (s *SpreadsheetService) sheetGrids(names []string) (map[string]*sheetGrid, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	spreadsheet, err := s.service.Spreadsheets.Get(s.spreadsheetID).
+		Fields("sheets.properties(sheetId,title,gridProperties(rowCount,columnCount))").
+		Do()
+	if err != nil {
+		return nil, err
+	}
+
+	grids := make(map[string]*sheetGrid, len(spreadsheet.Sheets)+1)
+	for i, sheet := range spreadsheet.Sheets {
+		grid := &sheetGrid{id: sheet.Properties.SheetId}
+		if p := sheet.Properties.GridProperties; p != nil {
+			grid.rows = p.RowCount
+			grid.columns = p.ColumnCount
+		}
+		if i == 0 {
+			grids[""] = grid
+		}
+		grids[sheet.Properties.Title] = grid
+	}
+
+	return grids, nil
+}
+
+// startIndex returns the zero based column and row index of the start of a range in A1 notation,
+// both may be left out (e.g. "A" or "1") and are then 0
+func startIndex(cell string) (int64, int64) {
+	letters := strings.TrimRight(cell, "0123456789")
+
+	var column int64
+	for _, c := range strings.ToUpper(letters) {
+		column = column*26 + int64(c-'A'+1)
+	}
+
+	row, _ := strconv.ParseInt(cell[len(letters):], 10, 64)
+
+	return max(column-1, 0), max(row-1, 0)
+}
+
+var cellPattern = regexp.MustCompile(`^([A-Za-z]{1,3})([1-9][0-9]*)$`)
+
+// gridRange converts the cells in A1 notation to a (zero based, end exclusive) grid range
+func gridRange(sheetID int64, startCell string, endCell string) (*sheets.GridRange, error) {
+	startColumn, startRow, err := cellIndex(startCell)
+	if err != nil {
+		return nil, err
+	}
+	endColumn, endRow, err := cellIndex(endCell)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sheets.GridRange{
+		SheetId:          sheetID,
+		StartColumnIndex: startColumn,
+		StartRowIndex:    startRow,
+		EndColumnIndex:   endColumn + 1,
+		EndRowIndex:      endRow + 1,
+		// Zero values (e.g. column A or row 1) would be omitted otherwise.
+		ForceSendFields: []string{"SheetId", "StartColumnIndex", "StartRowIndex"},
+	}, nil
+}
+
+// ColumnName returns the letters of the zero based column index, e.g. 0 is A and 26 is AA
+func ColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// cellIndex returns the zero based column and row index of a cell in A1 notation
+func cellIndex(cell string) (int64, int64, error) {
+	match := cellPattern.FindStringSubmatch(cell)
+	if match == nil {
+		return 0, 0, fmt.Errorf("%s is not a cell in A1 notation", cell)
+	}
+
+	var column int64
+	for _, c := range strings.ToUpper(match[1]) {
+		column = column*26 + int64(c-'A'+1)
+	}
+
+	row, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing row of %s: %w", cell, err)
+	}
+
+	return column - 1, row - 1, nil
+}
@@ -3,8 +3,12 @@ package steam
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 )
 
 // SteamStatus represents the status of the Steam services
@@ -28,8 +32,62 @@ func (s SteamStatus) IsDelayed() bool {
 	return s.Sessions == delayed || s.Community == delayed
 }
 
-// GetSteamStatus returns the current status of the Steam services
+// Limiter is asked for permission before a Client sends a request
+type Limiter interface {
+	Take(n int) error
+}
+
+// Option is a function that modifies the client
+type Option func(*Client)
+
+// WithLimiter makes the client take from l before every request
+func WithLimiter(l Limiter) Option {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// WithHTTPClient sets the http client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Client sends requests to the Steam API and community
+type Client struct {
+	httpClient *http.Client
+	limiter    Limiter
+}
+
+// NewClient creates a new client with the given options,
+// without a limiter requests are not rate limited
+func NewClient(options ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+var defaultClient = NewClient()
+
+// GetSteamStatus returns the current status of the Steam services using the default client
 func GetSteamStatus(apiKey string) (SteamStatus, error) {
+	return defaultClient.GetSteamStatus(apiKey)
+}
+
+// GetInventory returns the inventory of the given Steam user using the default client
+func GetInventory(steamID uint64) (SteamInventoryResponse, error) {
+	return defaultClient.GetInventory(steamID)
+}
+
+// GetSteamStatus returns the current status of the Steam services
+func (c *Client) GetSteamStatus(apiKey string) (SteamStatus, error) {
 	if apiKey == "" {
 		return SteamStatus{}, fmt.Errorf("api key is empty")
 	}
@@ -40,7 +98,11 @@ func GetSteamStatus(apiKey string) (SteamStatus, error) {
 	}
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	if err := c.take(); err != nil {
+		return SteamStatus{}, err
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return SteamStatus{}, fmt.Errorf("error sending request: %v", err)
 	}
@@ -71,64 +133,78 @@ type SteamInventoryResponse struct {
 		Instanceid string `json:"instanceid"`
 		Amount     string `json:"amount"`
 	} `json:"assets"`
-	Descriptions []struct {
-		Appid           int    `json:"appid"`
-		Classid         string `json:"classid"`
-		Instanceid      string `json:"instanceid"`
-		Currency        int    `json:"currency"`
-		BackgroundColor string `json:"background_color"`
-		IconURL         string `json:"icon_url"`
-		IconURLLarge    string `json:"icon_url_large,omitempty"`
-		Descriptions    []struct {
-			Type  string `json:"type"`
-			Value string `json:"value"`
-			Color string `json:"color,omitempty"`
-		} `json:"descriptions"`
-		Tradable int `json:"tradable"`
-		Actions  []struct {
-			Link string `json:"link"`
-			Name string `json:"name"`
-		} `json:"actions,omitempty"`
-		Name           string `json:"name"`
-		NameColor      string `json:"name_color"`
-		Type           string `json:"type"`
-		MarketName     string `json:"market_name"`
-		MarketHashName string `json:"market_hash_name"`
-		MarketActions  []struct {
-			Link string `json:"link"`
-			Name string `json:"name"`
-		} `json:"market_actions,omitempty"`
-		Commodity                 int `json:"commodity"`
-		MarketTradableRestriction int `json:"market_tradable_restriction"`
-		Marketable                int `json:"marketable"`
-		Tags                      []struct {
-			Category              string `json:"category"`
-			InternalName          string `json:"internal_name"`
-			LocalizedCategoryName string `json:"localized_category_name"`
-			LocalizedTagName      string `json:"localized_tag_name"`
-			Color                 string `json:"color,omitempty"`
-		} `json:"tags"`
-		Fraudwarnings []string `json:"fraudwarnings,omitempty"`
+	Descriptions        []InventoryDescription `json:"descriptions"`
+	TotalInventoryCount int                    `json:"total_inventory_count"`
+	Success             int                    `json:"success"`
+	Rwgrsn              int                    `json:"rwgrsn"`
+}
+
+// InventoryDescription describes an item (class) of a Steam inventory
+type InventoryDescription struct {
+	Appid           int    `json:"appid"`
+	Classid         string `json:"classid"`
+	Instanceid      string `json:"instanceid"`
+	Currency        int    `json:"currency"`
+	BackgroundColor string `json:"background_color"`
+	IconURL         string `json:"icon_url"`
+	IconURLLarge    string `json:"icon_url_large,omitempty"`
+	Descriptions    []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+		Color string `json:"color,omitempty"`
 	} `json:"descriptions"`
-	TotalInventoryCount int `json:"total_inventory_count"`
-	Success             int `json:"success"`
-	Rwgrsn              int `json:"rwgrsn"`
+	Tradable int `json:"tradable"`
+	Actions  []struct {
+		Link string `json:"link"`
+		Name string `json:"name"`
+	} `json:"actions,omitempty"`
+	Name           string `json:"name"`
+	NameColor      string `json:"name_color"`
+	Type           string `json:"type"`
+	MarketName     string `json:"market_name"`
+	MarketHashName string `json:"market_hash_name"`
+	MarketActions  []struct {
+		Link string `json:"link"`
+		Name string `json:"name"`
+	} `json:"market_actions,omitempty"`
+	Commodity                 int            `json:"commodity"`
+	MarketTradableRestriction int            `json:"market_tradable_restriction"`
+	Marketable                int            `json:"marketable"`
+	Tags                      []InventoryTag `json:"tags"`
+	Fraudwarnings             []string       `json:"fraudwarnings,omitempty"`
+}
+
+// InventoryTag is a tag of an InventoryDescription, e.g. its rarity or exterior
+type InventoryTag struct {
+	Category              string `json:"category"`
+	InternalName          string `json:"internal_name"`
+	LocalizedCategoryName string `json:"localized_category_name"`
+	LocalizedTagName      string `json:"localized_tag_name"`
+	Color                 string `json:"color,omitempty"`
 }
 
 // GetInventory returns the inventory of the given Steam user
-func GetInventory(steamID uint64) (SteamInventoryResponse, error) {
+func (c *Client) GetInventory(steamID uint64) (SteamInventoryResponse, error) {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(inventoryURL, steamID), nil)
 	if err != nil {
 		return SteamInventoryResponse{}, fmt.Errorf("error creating request: %v", err)
 	}
 	req.Header.Add("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	if err := c.take(); err != nil {
+		return SteamInventoryResponse{}, err
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return SteamInventoryResponse{}, fmt.Errorf("error sending request: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusForbidden {
+		return SteamInventoryResponse{}, ErrInventoryPrivate
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return SteamInventoryResponse{}, fmt.Errorf("error getting inventory: %v", resp.Status)
 	}
@@ -141,6 +217,53 @@ func GetInventory(steamID uint64) (SteamInventoryResponse, error) {
 	return inventory, nil
 }
 
+// ErrInventoryPrivate is returned if the inventory of a user is not public
+var ErrInventoryPrivate = errors.New("inventory is private")
+
+// ResolveVanityURL returns the SteamID64 of the user with the given custom profile URL name
+func (c *Client) ResolveVanityURL(apiKey string, vanityName string) (uint64, error) {
+	if apiKey == "" {
+		return 0, fmt.Errorf("api key is empty")
+	}
+
+	u := fmt.Sprintf(resolveVanityURL, url.QueryEscape(apiKey), url.QueryEscape(vanityName))
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	if err := c.take(); err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("error resolving vanity url: %v", resp.Status)
+	}
+
+	var vanity vanityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&vanity); err != nil {
+		return 0, fmt.Errorf("error decoding response: %v", err)
+	}
+
+	if vanity.Response.Success != 1 {
+		return 0, fmt.Errorf("no user found for vanity name %s", vanityName)
+	}
+
+	steamID, err := strconv.ParseUint(vanity.Response.SteamID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing steam id: %v", err)
+	}
+
+	return steamID, nil
+}
+
 // Gets a supported currency by it's ISO4217 code
 func GetCurrencySignByCode(isoCode string) (string, error) {
 	for code, sign := range supportedCurrenties {
@@ -151,6 +274,16 @@ func GetCurrencySignByCode(isoCode string) (string, error) {
 	return "", fmt.Errorf("currency %s not supported", isoCode)
 }
 
+// SupportedCurrencies returns the ISO4217 codes of all supported currencies, sorted
+func SupportedCurrencies() []string {
+	codes := make([]string, 0, len(supportedCurrenties))
+	for code := range supportedCurrenties {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
 var (
 	supportedCurrenties = map[string]string{
 		"USD": "$",
@@ -219,8 +352,18 @@ const (
 const (
 	statusURL    = "https://api.steampowered.com/ICSGOServers_730/GetGameServersStatus/v1/?key=%s"
 	inventoryURL = "https://steamcommunity.com/inventory/%d/730/2"
+	// Key and vanity name need to be query escaped.
+	resolveVanityURL = "https://api.steampowered.com/ISteamUser/ResolveVanityURL/v1/?key=%s&vanityurl=%s"
 )
 
+type vanityResponse struct {
+	Response struct {
+		SteamID string `json:"steamid"`
+		Success int    `json:"success"`
+		Message string `json:"message"`
+	} `json:"response"`
+}
+
 type statusResponse struct {
 	Result struct {
 		App struct {
@@ -380,6 +523,16 @@ type statusResponse struct {
 	} `json:"result"`
 }
 
+func (c *Client) take() error {
+	if c.limiter == nil {
+		return nil
+	}
+	if err := c.limiter.Take(1); err != nil {
+		return fmt.Errorf("error taking from rate limit: %w", err)
+	}
+	return nil
+}
+
 func parseStatus(s string) status {
 	switch s {
 	case "normal":